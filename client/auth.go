@@ -0,0 +1,99 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dynamicgo/restrpc"
+)
+
+// Auth .
+type Auth interface {
+	Handle(request *http.Request)
+}
+
+// HMACAuth sign requests with shared key, verified by server.HMACMiddleware
+type HMACAuth struct {
+	KeyID string
+	Key   []byte
+}
+
+// Handle .
+func (auth *HMACAuth) Handle(request *http.Request) {
+
+	body := readBody(request)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+
+	stringToSign := restrpc.StringToSign(request.Method, request.URL.EscapedPath(), request.URL.Query().Encode(), timestamp, hex.EncodeToString(nonce), body)
+
+	request.Header.Set(restrpc.HeaderKeyID, auth.KeyID)
+	request.Header.Set(restrpc.HeaderTimestamp, timestamp)
+	request.Header.Set(restrpc.HeaderNonce, hex.EncodeToString(nonce))
+	request.Header.Set(restrpc.HeaderSignature, restrpc.Sign(auth.Key, stringToSign))
+}
+
+// readBody read request body without consuming it
+func readBody(request *http.Request) []byte {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil
+	}
+
+	if request.GetBody != nil {
+		if body, err := request.GetBody(); err == nil {
+			defer body.Close()
+			buff, _ := ioutil.ReadAll(body)
+			return buff
+		}
+	}
+
+	buff, _ := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	request.Body = ioutil.NopCloser(bytes.NewReader(buff))
+
+	return buff
+}
+
+// APIKeyAuth send api key in header or query, verified by server.APIKeyMiddleware
+type APIKeyAuth struct {
+	Key    string
+	Header string // header name, default X-API-Key
+	Query  string // query parameter name, if set the key is sent in query instead of header
+}
+
+// Handle .
+func (auth *APIKeyAuth) Handle(request *http.Request) {
+	if auth.Query != "" {
+		query := request.URL.Query()
+		query.Set(auth.Query, auth.Key)
+		request.URL.RawQuery = query.Encode()
+		return
+	}
+
+	header := auth.Header
+
+	if header == "" {
+		header = "X-API-Key"
+	}
+
+	request.Header.Set(header, auth.Key)
+}
+
+// BasicAuth HTTP Basic auth, verified by server.BasicAuthMiddleware
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Handle .
+func (auth *BasicAuth) Handle(request *http.Request) {
+	request.SetBasicAuth(auth.Username, auth.Password)
+}
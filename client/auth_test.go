@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/restrpc/server"
+	"github.com/stretchr/testify/require"
+)
+
+// replayAuth replays the first signed request headers
+type replayAuth struct {
+	auth   Auth
+	header http.Header
+}
+
+func (auth *replayAuth) Handle(request *http.Request) {
+	if auth.header == nil {
+		auth.auth.Handle(request)
+		auth.header = request.Header.Clone()
+		return
+	}
+
+	request.Header = auth.header
+}
+
+func TestHMACAuth(t *testing.T) {
+	testServer := httptest.NewServer(server.New().Handle("/test", &echoService{}, server.HMACMiddleware(server.HMACConfig{
+		Keys: map[string][]byte{"service-a": []byte("secret")},
+	})))
+	defer testServer.Close()
+
+	client := New(testServer.URL)
+
+	var reply echoResult
+
+	require.NoError(t, client.Call("/test/echo", http.MethodPost, &echoParam{Count: 2}, &reply, WithAuth(&HMACAuth{KeyID: "service-a", Key: []byte("secret")})))
+	require.Equal(t, 2, reply.Count)
+
+	require.NoError(t, client.Call("/test/echo", http.MethodGet, &echoParam{Count: 3}, &reply, WithAuth(&HMACAuth{KeyID: "service-a", Key: []byte("secret")})))
+	require.Equal(t, 3, reply.Count)
+
+	err := client.Call("/test/echo", http.MethodGet, nil, &reply, WithAuth(&HMACAuth{KeyID: "service-a", Key: []byte("wrong")}))
+	requireAPICode(t, err, server.ErrSignatureInvalid.Code())
+
+	err = client.Call("/test/echo", http.MethodGet, nil, &reply, WithAuth(&HMACAuth{KeyID: "service-b", Key: []byte("secret")}))
+	requireAPICode(t, err, server.ErrSignatureKey.Code())
+
+	err = client.Call("/test/echo", http.MethodGet, nil, &reply)
+	requireAPICode(t, err, server.ErrSignatureMissing.Code())
+
+	replay := &replayAuth{auth: &HMACAuth{KeyID: "service-a", Key: []byte("secret")}}
+
+	require.NoError(t, client.Call("/test/echo", http.MethodGet, nil, &reply, WithAuth(replay)))
+
+	err = client.Call("/test/echo", http.MethodGet, nil, &reply, WithAuth(replay))
+	requireAPICode(t, err, server.ErrSignatureReplay.Code())
+}
+
+type principalService struct {
+}
+
+type principalResult struct {
+	Name string `json:"name"`
+}
+
+func (service *principalService) GetPrincipal(ctx context.Context, p *echoParam, r *principalResult) error {
+	principal, _ := server.PrincipalFromContext(ctx)
+	r.Name = principal.Name
+	return nil
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	store := server.NewMemoryStore(server.Credential{
+		Principal: server.Principal{Name: "alice"},
+		Keys: []server.APIKey{
+			{Key: "old", Expires: time.Now().Add(-time.Minute)},
+			{Key: "current"},
+			{Key: "sha256:" + sha256Hex("next")},
+		},
+	})
+
+	testServer := httptest.NewServer(server.New().Handle("/test", &principalService{}, server.APIKeyMiddleware(store, server.APIKeyConfig{Query: "api_key"})))
+	defer testServer.Close()
+
+	client := New(testServer.URL)
+
+	var reply principalResult
+
+	require.NoError(t, client.Call("/test/principal", http.MethodGet, nil, &reply, WithAuth(&APIKeyAuth{Key: "current"})))
+	require.Equal(t, "alice", reply.Name)
+
+	require.NoError(t, client.Call("/test/principal", http.MethodGet, nil, &reply, WithAuth(&APIKeyAuth{Key: "next", Query: "api_key"})))
+
+	err := client.Call("/test/principal", http.MethodGet, nil, &reply, WithAuth(&APIKeyAuth{Key: "old"}))
+	requireAPICode(t, err, server.ErrCredentialsInvalid.Code())
+
+	err = client.Call("/test/principal", http.MethodGet, nil, &reply)
+	requireAPICode(t, err, server.ErrCredentialsMissing.Code())
+}
+
+func TestBasicAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credential")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials.yaml")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("- name: bob\n  password: secret\n"), 0644))
+
+	store, err := server.NewFileStore(path, time.Minute)
+	require.NoError(t, err)
+
+	testServer := httptest.NewServer(server.New().Handle("/test", &principalService{}, server.BasicAuthMiddleware(store, "test")))
+	defer testServer.Close()
+
+	client := New(testServer.URL)
+
+	var reply principalResult
+
+	require.NoError(t, client.Call("/test/principal", http.MethodGet, nil, &reply, WithAuth(&BasicAuth{Username: "bob", Password: "secret"})))
+	require.Equal(t, "bob", reply.Name)
+
+	err = client.Call("/test/principal", http.MethodGet, nil, &reply, WithAuth(&BasicAuth{Username: "bob", Password: "wrong"}))
+	requireAPICode(t, err, server.ErrCredentialsInvalid.Code())
+}
+
+func sha256Hex(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
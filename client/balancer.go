@@ -0,0 +1,387 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dynamicgo/restrpc"
+)
+
+// Balancer errors
+var (
+	ErrNoEndpoint = errors.New("no available endpoint")
+)
+
+// Endpoint service endpoint
+type Endpoint struct {
+	URL    string // root url
+	Weight int    // weight used by StrategyWeighted, <= 0 means 1
+}
+
+// Resolver resolve service endpoints
+type Resolver interface {
+	// Resolve returns current endpoints
+	Resolve(ctx context.Context) ([]Endpoint, error)
+	// Watch calls update with the new endpoint set on every change and blocks until ctx done,
+	// resolvers never change may return immediately
+	Watch(ctx context.Context, update func(endpoints []Endpoint)) error
+}
+
+type staticResolver struct {
+	endpoints []Endpoint
+}
+
+// NewStaticResolver create resolver with fixed endpoints
+func NewStaticResolver(endpoints ...Endpoint) Resolver {
+	return &staticResolver{
+		endpoints: endpoints,
+	}
+}
+
+func (resolver *staticResolver) Resolve(ctx context.Context) ([]Endpoint, error) {
+	return resolver.endpoints, nil
+}
+
+func (resolver *staticResolver) Watch(ctx context.Context, update func(endpoints []Endpoint)) error {
+	return nil
+}
+
+// Strategy load balance strategy
+type Strategy int
+
+// Load balance strategies
+const (
+	StrategyRoundRobin Strategy = iota
+	StrategyLeastInFlight
+	StrategyWeighted
+)
+
+// OutlierSettings passive outlier ejection settings
+type OutlierSettings struct {
+	ConsecutiveFailures int                              // eject endpoint after n consecutive failures
+	EjectionTime        time.Duration                    // base ejection time, multiplied by ejection times
+	MaxEjectionTime     time.Duration                    // ejection time upper limit
+	IsFailure           func(status int, err error) bool // failure classifier, status is 0 for network errors, default is IsEndpointFailure
+}
+
+// HealthCheck active health check settings
+type HealthCheck struct {
+	Path     string        // health check path, e.g. /health
+	Interval time.Duration // check interval
+	Timeout  time.Duration // check request timeout
+}
+
+// IsEndpointFailure default outlier failure classifier: network errors, 429 and 5xx responses count as
+// endpoint failures, except 500 responses carrying an error returned by the service
+func IsEndpointFailure(status int, err error) bool {
+	switch {
+	case status == 0:
+		return err != nil
+	case status == http.StatusTooManyRequests:
+		return true
+	case status == http.StatusInternalServerError:
+		// restrpc servers answer service errors with 500, only bodies that are not error responses count
+		return errors.Is(err, restrpc.ErrInternal)
+	default:
+		return status > http.StatusInternalServerError
+	}
+}
+
+// WithBalanceStrategy set load balance strategy
+func WithBalanceStrategy(strategy Strategy) ClientOption {
+	return func(client *clientImpl) {
+		client.balancer.strategy = strategy
+	}
+}
+
+// WithOutlierEjection enable passive outlier ejection
+func WithOutlierEjection(settings OutlierSettings) ClientOption {
+	return func(client *clientImpl) {
+		if settings.ConsecutiveFailures <= 0 {
+			settings.ConsecutiveFailures = 5
+		}
+
+		if settings.EjectionTime <= 0 {
+			settings.EjectionTime = 30 * time.Second
+		}
+
+		if settings.MaxEjectionTime < settings.EjectionTime {
+			settings.MaxEjectionTime = 10 * settings.EjectionTime
+		}
+
+		if settings.IsFailure == nil {
+			settings.IsFailure = IsEndpointFailure
+		}
+
+		client.balancer.outlier = &settings
+	}
+}
+
+// WithHealthCheck enable active health check
+func WithHealthCheck(check HealthCheck) ClientOption {
+	return func(client *clientImpl) {
+		if check.Interval <= 0 {
+			check.Interval = 10 * time.Second
+		}
+
+		if check.Timeout <= 0 {
+			check.Timeout = time.Second
+		}
+
+		client.balancer.healthCheck = &check
+	}
+}
+
+type endpointState struct {
+	url           string
+	weight        int
+	currentWeight int
+	inFlight      int
+	failures      int
+	ejections     int
+	ejectedUntil  time.Time
+	unhealthy     bool
+}
+
+func (state *endpointState) available(now time.Time) bool {
+	return !state.unhealthy && !state.ejectedUntil.After(now)
+}
+
+type balancer struct {
+	sync.Mutex
+	resolver    Resolver
+	strategy    Strategy
+	outlier     *OutlierSettings
+	healthCheck *HealthCheck
+	endpoints   []*endpointState
+	next        int
+	cancel      context.CancelFunc
+}
+
+func newBalancer(resolver Resolver) *balancer {
+	return &balancer{
+		resolver: resolver,
+		cancel:   func() {},
+	}
+}
+
+// start resolve endpoints and start the watch and health check goroutines
+func (lb *balancer) start(client *clientImpl) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lb.cancel = cancel
+
+	endpoints, err := lb.resolver.Resolve(ctx)
+
+	if err != nil {
+		client.ErrorF("resolve endpoints error: %s", err)
+	} else {
+		lb.update(endpoints)
+	}
+
+	go func() {
+		if err := lb.resolver.Watch(ctx, lb.update); err != nil {
+			client.ErrorF("watch endpoints error: %s", err)
+		}
+	}()
+
+	if lb.healthCheck != nil {
+		go lb.runHealthCheck(ctx, client.rawClient)
+	}
+}
+
+func (lb *balancer) stop() {
+	lb.cancel()
+}
+
+func (lb *balancer) update(endpoints []Endpoint) {
+	lb.Lock()
+	defer lb.Unlock()
+
+	current := make(map[string]*endpointState)
+
+	for _, state := range lb.endpoints {
+		current[state.url] = state
+	}
+
+	var states []*endpointState
+
+	for _, endpoint := range endpoints {
+		url := strings.TrimSuffix(endpoint.URL, "/")
+
+		weight := endpoint.Weight
+
+		if weight <= 0 {
+			weight = 1
+		}
+
+		state, ok := current[url]
+
+		if !ok {
+			state = &endpointState{url: url}
+		}
+
+		state.weight = weight
+
+		states = append(states, state)
+	}
+
+	lb.endpoints = states
+}
+
+func (lb *balancer) pick() (*endpointState, error) {
+	lb.Lock()
+	defer lb.Unlock()
+
+	now := time.Now()
+
+	var candidates []*endpointState
+
+	for _, state := range lb.endpoints {
+		if state.available(now) {
+			candidates = append(candidates, state)
+		}
+	}
+
+	// panic mode: all endpoints are ejected or unhealthy
+	if len(candidates) == 0 {
+		candidates = lb.endpoints
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoEndpoint
+	}
+
+	var picked *endpointState
+
+	switch lb.strategy {
+	case StrategyLeastInFlight:
+		for i := 0; i < len(candidates); i++ {
+			state := candidates[(lb.next+i)%len(candidates)]
+
+			if picked == nil || state.inFlight < picked.inFlight {
+				picked = state
+			}
+		}
+
+		lb.next++
+	case StrategyWeighted:
+		// smooth weighted round robin
+		total := 0
+
+		for _, state := range candidates {
+			state.currentWeight += state.weight
+			total += state.weight
+
+			if picked == nil || state.currentWeight > picked.currentWeight {
+				picked = state
+			}
+		}
+
+		picked.currentWeight -= total
+	default:
+		picked = candidates[lb.next%len(candidates)]
+		lb.next++
+	}
+
+	picked.inFlight++
+
+	return picked, nil
+}
+
+// failed classify the call result of an endpoint with the outlier classifier
+func (lb *balancer) failed(status int, err error) bool {
+	if lb.outlier == nil {
+		return false
+	}
+
+	return lb.outlier.IsFailure(status, err)
+}
+
+func (lb *balancer) done(state *endpointState, failed bool) {
+	lb.Lock()
+	defer lb.Unlock()
+
+	state.inFlight--
+
+	if lb.outlier == nil {
+		return
+	}
+
+	if !failed {
+		state.failures = 0
+		return
+	}
+
+	state.failures++
+
+	if state.failures < lb.outlier.ConsecutiveFailures {
+		return
+	}
+
+	state.failures = 0
+	state.ejections++
+
+	ejection := time.Duration(state.ejections) * lb.outlier.EjectionTime
+
+	if ejection > lb.outlier.MaxEjectionTime {
+		ejection = lb.outlier.MaxEjectionTime
+	}
+
+	state.ejectedUntil = time.Now().Add(ejection)
+}
+
+func (lb *balancer) runHealthCheck(ctx context.Context, httpClient *http.Client) {
+	ticker := time.NewTicker(lb.healthCheck.Interval)
+	defer ticker.Stop()
+
+	for {
+		lb.checkHealth(ctx, httpClient)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (lb *balancer) checkHealth(ctx context.Context, httpClient *http.Client) {
+	lb.Lock()
+	endpoints := append([]*endpointState(nil), lb.endpoints...)
+	lb.Unlock()
+
+	for _, state := range endpoints {
+		healthy := lb.probe(ctx, httpClient, state.url)
+
+		lb.Lock()
+		state.unhealthy = !healthy
+		lb.Unlock()
+	}
+}
+
+func (lb *balancer) probe(ctx context.Context, httpClient *http.Client, url string) bool {
+	ctx, cancel := context.WithTimeout(ctx, lb.healthCheck.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", url, strings.TrimPrefix(lb.healthCheck.Path, "/")), nil)
+
+	if err != nil {
+		return false
+	}
+
+	resp, err := httpClient.Do(request.WithContext(ctx))
+
+	if err != nil {
+		return false
+	}
+
+	resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/xerrors"
+	"github.com/dynamicgo/xerrors/apierr"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBalancer(strategy Strategy, endpoints ...Endpoint) *balancer {
+	lb := newBalancer(NewStaticResolver(endpoints...))
+	lb.strategy = strategy
+	lb.update(endpoints)
+	return lb
+}
+
+func pickURLs(t *testing.T, lb *balancer, n int) []string {
+	var urls []string
+
+	for i := 0; i < n; i++ {
+		state, err := lb.pick()
+		require.NoError(t, err)
+		lb.done(state, false)
+		urls = append(urls, state.url)
+	}
+
+	return urls
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	lb := newTestBalancer(StrategyRoundRobin, Endpoint{URL: "a"}, Endpoint{URL: "b"})
+
+	require.Equal(t, []string{"a", "b", "a", "b"}, pickURLs(t, lb, 4))
+}
+
+func TestBalancerWeighted(t *testing.T) {
+	lb := newTestBalancer(StrategyWeighted, Endpoint{URL: "a", Weight: 2}, Endpoint{URL: "b"})
+
+	require.Equal(t, []string{"a", "b", "a", "a", "b", "a"}, pickURLs(t, lb, 6))
+}
+
+func TestBalancerLeastInFlight(t *testing.T) {
+	lb := newTestBalancer(StrategyLeastInFlight, Endpoint{URL: "a"}, Endpoint{URL: "b"})
+
+	first, _ := lb.pick()
+	second, _ := lb.pick()
+
+	require.NotEqual(t, first.url, second.url)
+
+	lb.done(first, false)
+
+	third, _ := lb.pick()
+
+	require.Equal(t, first.url, third.url)
+}
+
+func TestBalancerOutlierEjection(t *testing.T) {
+	lb := newTestBalancer(StrategyRoundRobin, Endpoint{URL: "a"}, Endpoint{URL: "b"})
+
+	lb.outlier = &OutlierSettings{
+		ConsecutiveFailures: 1,
+		EjectionTime:        time.Minute,
+		MaxEjectionTime:     time.Minute,
+		IsFailure:           IsEndpointFailure,
+	}
+
+	state, _ := lb.pick()
+
+	lb.done(state, true)
+
+	require.Equal(t, []string{"b", "b"}, pickURLs(t, lb, 2))
+}
+
+func TestEndpointFailure(t *testing.T) {
+	network := errors.New("network error")
+
+	require.True(t, IsEndpointFailure(0, network))
+	require.True(t, IsEndpointFailure(http.StatusTooManyRequests, apierr.New(-1, "too many requests")))
+	require.True(t, IsEndpointFailure(http.StatusServiceUnavailable, xerrors.Wrapf(restrpc.ErrInternal, "unexpected status")))
+	require.True(t, IsEndpointFailure(http.StatusInternalServerError, xerrors.Wrapf(restrpc.ErrInternal, "unmarshal")))
+	require.False(t, IsEndpointFailure(http.StatusInternalServerError, apierr.New(-1, "user not found")))
+	require.False(t, IsEndpointFailure(http.StatusInternalServerError, apierr.New(1001, "user not found")))
+	require.False(t, IsEndpointFailure(http.StatusOK, nil))
+}
+
+func TestBalancerServiceErrors(t *testing.T) {
+	var hits [2]int
+
+	newServer := func(i int, status int, body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i]++
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+	}
+
+	failing := newServer(0, http.StatusInternalServerError, `{"code":-1,"errmsg":"user not found"}`)
+	defer failing.Close()
+
+	healthy := newServer(1, http.StatusOK, `{"result":{}}`)
+	defer healthy.Close()
+
+	client := NewWithResolver(NewStaticResolver(Endpoint{URL: failing.URL}, Endpoint{URL: healthy.URL}),
+		WithOutlierEjection(OutlierSettings{ConsecutiveFailures: 1}))
+	defer client.Close()
+
+	var reply struct{}
+
+	for i := 0; i < 4; i++ {
+		client.Call("/test/hello", http.MethodGet, nil, &reply)
+	}
+
+	require.Equal(t, [2]int{2, 2}, hits)
+}
+
+func TestBalancerHealthCheck(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":{}}`))
+	}))
+	defer healthy.Close()
+
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+
+	client := NewWithResolver(NewStaticResolver(Endpoint{URL: unhealthy.URL}, Endpoint{URL: healthy.URL}),
+		WithHealthCheck(HealthCheck{Path: "/health", Interval: time.Hour}))
+	defer client.Close()
+
+	lb := client.(*clientImpl).balancer
+
+	require.Eventually(t, func() bool {
+		lb.Lock()
+		defer lb.Unlock()
+		return lb.endpoints[0].unhealthy
+	}, time.Second, 10*time.Millisecond)
+
+	var reply struct{}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, client.Call("/test/hello", http.MethodGet, nil, &reply))
+	}
+}
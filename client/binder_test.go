@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dynamicgo/restrpc/server"
+	"github.com/dynamicgo/restrpc/validator"
+	"github.com/stretchr/testify/require"
+)
+
+type bigIntBinder struct {
+}
+
+func (binder *bigIntBinder) Bind(text string, metadata *validator.Metadata) (interface{}, error) {
+	number, ok := new(big.Int).SetString(text, 10)
+
+	if !ok {
+		return nil, errors.New("invalid big int")
+	}
+
+	return number, nil
+}
+
+func (binder *bigIntBinder) Format(value interface{}, metadata *validator.Metadata) (string, error) {
+	return value.(*big.Int).String(), nil
+}
+
+func init() {
+	validator.Register(reflect.TypeOf(big.Int{}), &bigIntBinder{})
+}
+
+type sumParam struct {
+	Numbers []*big.Int `rest:"numbers"`
+}
+
+type sumResult struct {
+	Sum string `json:"sum"`
+}
+
+type sumService struct {
+}
+
+func (service *sumService) GetSum(p *sumParam, r *sumResult) error {
+	sum := new(big.Int)
+
+	for _, number := range p.Numbers {
+		sum.Add(sum, number)
+	}
+
+	r.Sum = sum.String()
+
+	return nil
+}
+
+func (service *sumService) PostSum(p *sumParam, r *sumResult) error {
+	return service.GetSum(p, r)
+}
+
+func TestRegisteredBinder(t *testing.T) {
+	testServer := httptest.NewServer(server.New().Handle("/test", &sumService{}))
+	defer testServer.Close()
+
+	first, _ := new(big.Int).SetString("100000000000000000000", 10)
+
+	param := &sumParam{Numbers: []*big.Int{first, big.NewInt(1)}}
+
+	client := New(testServer.URL)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		var reply sumResult
+
+		require.NoError(t, client.Call("/test/sum", method, param, &reply))
+		require.Equal(t, "100000000000000000001", reply.Sum, method)
+	}
+}
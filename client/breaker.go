@@ -0,0 +1,296 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/xerrors/apierr"
+)
+
+// BreakerState circuit breaker state
+type BreakerState int
+
+// Circuit breaker states
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (state BreakerState) String() string {
+	switch state {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(state))
+	}
+}
+
+// BreakerSettings circuit breaker settings, applied to every service path separately
+type BreakerSettings struct {
+	ConsecutiveFailures uint32                                   // open after n consecutive failures, 0 disable
+	FailureRatio        float64                                  // open when failures/requests reach ratio, 0 disable
+	MinRequests         uint32                                   // minimum requests in interval before FailureRatio applies
+	Interval            time.Duration                            // closed state counters reset period, 0 never reset
+	Cooldown            time.Duration                            // open state duration before half-open
+	HalfOpenRequests    uint32                                   // max probe requests in half-open state
+	IsFailure           func(err error) bool                     // failure classifier, default is IsBreakerFailure
+	OnStateChange       func(path string, from, to BreakerState) // state change hook, called outside the breaker lock
+}
+
+// Fallback called when the call is rejected by circuit breaker or failed,
+// the returned error replaces the call error
+type Fallback func(path string, method string, name string, args interface{}, reply interface{}, cause error) error
+
+// BreakerOpenError returned when the circuit breaker rejects a call
+type BreakerOpenError struct {
+	Path       string
+	State      BreakerState
+	RetryAfter time.Duration
+}
+
+func (err *BreakerOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker of service %s is %s, retry after %s", err.Path, err.State, err.RetryAfter)
+}
+
+// IsBreakerFailure default failure classifier: network error and restrpc internal error
+// are counted as failures, api errors returned by services are not
+func IsBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	apiErr := apierr.As(err, nil)
+
+	if apiErr == nil {
+		return true
+	}
+
+	return apiErr.Code() == restrpc.ErrInternal.Code()
+}
+
+type breakerCounts struct {
+	requests             uint32
+	failures             uint32
+	consecutiveFailures  uint32
+	consecutiveSuccesses uint32
+}
+
+type breakerTransition struct {
+	from, to BreakerState
+}
+
+type breaker struct {
+	sync.Mutex
+	path        string
+	settings    BreakerSettings
+	state       BreakerState
+	generation  uint64
+	counts      breakerCounts
+	expiry      time.Time
+	transitions []breakerTransition // state changes to report after unlock
+}
+
+func newBreaker(path string, settings BreakerSettings) *breaker {
+
+	if settings.Cooldown <= 0 {
+		settings.Cooldown = 30 * time.Second
+	}
+
+	if settings.HalfOpenRequests == 0 {
+		settings.HalfOpenRequests = 1
+	}
+
+	if settings.IsFailure == nil {
+		settings.IsFailure = IsBreakerFailure
+	}
+
+	cb := &breaker{
+		path:     path,
+		settings: settings,
+	}
+
+	cb.toNewGeneration(time.Now())
+
+	return cb
+}
+
+// getState returns the current breaker state
+func (cb *breaker) getState() BreakerState {
+	cb.Lock()
+	defer cb.unlock()
+
+	state, _ := cb.currentState(time.Now())
+
+	return state
+}
+
+// Execute run f if breaker allowed
+func (cb *breaker) Execute(f func() error) error {
+	generation, err := cb.beforeCall()
+
+	if err != nil {
+		return err
+	}
+
+	err = f()
+
+	cb.afterCall(generation, !cb.settings.IsFailure(err))
+
+	return err
+}
+
+func (cb *breaker) beforeCall() (uint64, error) {
+	cb.Lock()
+	defer cb.unlock()
+
+	now := time.Now()
+
+	state, generation := cb.currentState(now)
+
+	if state == StateOpen {
+		return generation, &BreakerOpenError{
+			Path:       cb.path,
+			State:      state,
+			RetryAfter: cb.expiry.Sub(now),
+		}
+	}
+
+	if state == StateHalfOpen && cb.counts.requests >= cb.settings.HalfOpenRequests {
+		return generation, &BreakerOpenError{
+			Path:  cb.path,
+			State: state,
+		}
+	}
+
+	cb.counts.requests++
+
+	return generation, nil
+}
+
+func (cb *breaker) afterCall(before uint64, success bool) {
+	cb.Lock()
+	defer cb.unlock()
+
+	now := time.Now()
+
+	state, generation := cb.currentState(now)
+
+	if generation != before {
+		return
+	}
+
+	if success {
+		cb.onSuccess(state, now)
+	} else {
+		cb.onFailure(state, now)
+	}
+}
+
+func (cb *breaker) onSuccess(state BreakerState, now time.Time) {
+	cb.counts.consecutiveSuccesses++
+	cb.counts.consecutiveFailures = 0
+
+	if state == StateHalfOpen && cb.counts.consecutiveSuccesses >= cb.settings.HalfOpenRequests {
+		cb.setState(StateClosed, now)
+	}
+}
+
+func (cb *breaker) onFailure(state BreakerState, now time.Time) {
+	cb.counts.failures++
+	cb.counts.consecutiveFailures++
+	cb.counts.consecutiveSuccesses = 0
+
+	switch state {
+	case StateClosed:
+		if cb.readyToTrip() {
+			cb.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		cb.setState(StateOpen, now)
+	}
+}
+
+func (cb *breaker) readyToTrip() bool {
+	settings := cb.settings
+
+	if settings.ConsecutiveFailures > 0 && cb.counts.consecutiveFailures >= settings.ConsecutiveFailures {
+		return true
+	}
+
+	if settings.FailureRatio > 0 && cb.counts.requests >= settings.MinRequests && cb.counts.requests > 0 {
+		return float64(cb.counts.failures)/float64(cb.counts.requests) >= settings.FailureRatio
+	}
+
+	return false
+}
+
+func (cb *breaker) currentState(now time.Time) (BreakerState, uint64) {
+	switch cb.state {
+	case StateClosed:
+		if !cb.expiry.IsZero() && cb.expiry.Before(now) {
+			cb.toNewGeneration(now)
+		}
+	case StateOpen:
+		if cb.expiry.Before(now) {
+			cb.setState(StateHalfOpen, now)
+		}
+	}
+
+	return cb.state, cb.generation
+}
+
+func (cb *breaker) setState(state BreakerState, now time.Time) {
+	if cb.state == state {
+		return
+	}
+
+	prev := cb.state
+
+	cb.state = state
+
+	cb.toNewGeneration(now)
+
+	cb.transitions = append(cb.transitions, breakerTransition{from: prev, to: state})
+}
+
+// unlock release the lock then call OnStateChange, so that hooks may use the breaker
+func (cb *breaker) unlock() {
+	transitions := cb.transitions
+
+	cb.transitions = nil
+
+	cb.Unlock()
+
+	if cb.settings.OnStateChange == nil {
+		return
+	}
+
+	for _, transition := range transitions {
+		cb.settings.OnStateChange(cb.path, transition.from, transition.to)
+	}
+}
+
+func (cb *breaker) toNewGeneration(now time.Time) {
+	cb.generation++
+	cb.counts = breakerCounts{}
+
+	switch cb.state {
+	case StateClosed:
+		if cb.settings.Interval == 0 {
+			cb.expiry = time.Time{}
+		} else {
+			cb.expiry = now.Add(cb.settings.Interval)
+		}
+	case StateOpen:
+		cb.expiry = now.Add(cb.settings.Cooldown)
+	default:
+		cb.expiry = time.Time{}
+	}
+}
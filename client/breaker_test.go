@@ -0,0 +1,92 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+
+	var changes []BreakerState
+
+	cb := newBreaker("/test", BreakerSettings{
+		ConsecutiveFailures: 2,
+		Cooldown:            50 * time.Millisecond,
+		OnStateChange: func(path string, from, to BreakerState) {
+			changes = append(changes, to)
+		},
+	})
+
+	failed := errors.New("network error")
+
+	require.Equal(t, failed, cb.Execute(func() error { return failed }))
+	require.Equal(t, failed, cb.Execute(func() error { return failed }))
+
+	err := cb.Execute(func() error { return nil })
+
+	_, ok := err.(*BreakerOpenError)
+
+	require.True(t, ok)
+
+	time.Sleep(60 * time.Millisecond)
+
+	require.NoError(t, cb.Execute(func() error { return nil }))
+
+	require.Equal(t, []BreakerState{StateOpen, StateHalfOpen, StateClosed}, changes)
+}
+
+func TestBreakerFailureRatio(t *testing.T) {
+	cb := newBreaker("/test", BreakerSettings{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+	})
+
+	failed := errors.New("network error")
+
+	cb.Execute(func() error { return nil })
+	cb.Execute(func() error { return failed })
+	cb.Execute(func() error { return nil })
+
+	require.Equal(t, StateClosed, cb.state)
+
+	cb.Execute(func() error { return failed })
+
+	require.Equal(t, StateOpen, cb.state)
+}
+
+func TestBreakerStateChangeHook(t *testing.T) {
+
+	var cb *breaker
+
+	var states []BreakerState
+
+	// the hook runs on the goroutine below, results are checked on the test goroutine
+	rejected := make(chan error, 1)
+
+	cb = newBreaker("/test", BreakerSettings{
+		ConsecutiveFailures: 1,
+		OnStateChange: func(path string, from, to BreakerState) {
+			states = append(states, cb.getState())
+			rejected <- cb.Execute(func() error { return nil })
+		},
+	})
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- cb.Execute(func() error { return errors.New("network error") })
+	}()
+
+	select {
+	case err := <-done:
+		require.EqualError(t, err, "network error")
+	case <-time.After(time.Second):
+		require.FailNow(t, "state change hook deadlocked")
+	}
+
+	require.Equal(t, []BreakerState{StateOpen}, states)
+	require.IsType(t, &BreakerOpenError{}, <-rejected)
+}
@@ -1,43 +1,26 @@
 package client
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dynamicgo/xerrors/apierr"
 
 	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/slf4go"
 	"github.com/dynamicgo/xerrors"
 	"github.com/go-resty/resty"
 )
 
-// Auth .
-type Auth interface {
-	Handle(request *http.Request)
-}
-
-// Option .
-type Option func(request *http.Request)
-
-// WithAuth add auth option
-func WithAuth(auth Auth) Option {
-	return func(request *http.Request) {
-		auth.Handle(request)
-	}
-}
-
-// WithJWToken .
-func WithJWToken(token string) Option {
-	return func(request *http.Request) {
-		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-	}
-}
-
 // ErrType .
 var (
 	ErrMethod = errors.New("unsupport method")
@@ -46,26 +29,153 @@ var (
 // Client .
 type Client interface {
 	Call(path string, method string, args interface{}, reply interface{}, options ...Option) error
+	CallContext(ctx context.Context, path string, method string, args interface{}, reply interface{}, options ...Option) error
 	Service(path string) Service
+	Close() error
 }
 
 // Service .
 type Service interface {
 	Call(method string, name string, args interface{}, reply interface{}, options ...Option) error
+	CallContext(ctx context.Context, method string, name string, args interface{}, reply interface{}, options ...Option) error
+}
+
+// ClientOption client config option
+type ClientOption func(client *clientImpl)
+
+// WithCircuitBreaker enable circuit breaker for every service path
+func WithCircuitBreaker(settings BreakerSettings) ClientOption {
+	return func(client *clientImpl) {
+		client.breakerSettings = &settings
+	}
+}
+
+// WithTimeout set default timeout for calls without context deadline
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(client *clientImpl) {
+		client.timeout = timeout
+	}
+}
+
+// WithFallback set fallback function called on failed or rejected calls
+func WithFallback(fallback Fallback) ClientOption {
+	return func(client *clientImpl) {
+		client.fallback = fallback
+	}
 }
 
 type clientImpl struct {
-	rootURL string
+	sync.Mutex
+	slf4go.Logger
+	balancer        *balancer
+	breakerSettings *BreakerSettings
+	breakers        map[string]*breaker
+	fallback        Fallback
+	retry           *RetryPolicy
+	timeout         time.Duration
+	interceptors    []Interceptor
+	httpClient      *http.Client
+	transport       http.RoundTripper
+	tlsConfig       *tls.Config
+	proxy           func(*http.Request) (*url.URL, error)
+	headers         http.Header
+	compressMinSize int
+	envelope        restrpc.Envelope
+	problemDetails  bool
+	rawClient       *http.Client
+	rest            *resty.Client
 }
 
 // New .
-func New(url string) Client {
-	return &clientImpl{
-		rootURL: url,
+func New(url string, options ...ClientOption) Client {
+	return NewWithResolver(NewStaticResolver(Endpoint{URL: url}), options...)
+}
+
+// NewWithResolver create client balancing calls across the endpoints provided by resolver
+func NewWithResolver(resolver Resolver, options ...ClientOption) Client {
+	client := &clientImpl{
+		Logger:   slf4go.Get("client"),
+		balancer: newBalancer(resolver),
+		breakers: make(map[string]*breaker),
+		headers:  make(http.Header),
+	}
+
+	for _, option := range options {
+		option(client)
+	}
+
+	client.rawClient = client.newHTTPClient()
+	client.rawClient.Transport = &decompressTransport{next: client.rawClient.Transport}
+	client.rest = resty.NewWithClient(client.rawClient)
+
+	client.balancer.start(client)
+
+	return client
+}
+
+func (client *clientImpl) Close() error {
+	client.balancer.stop()
+	return nil
+}
+
+func (client *clientImpl) newHTTPClient() *http.Client {
+	httpClient := &http.Client{}
+
+	if client.httpClient != nil {
+		*httpClient = *client.httpClient
+	}
+
+	if client.transport != nil {
+		httpClient.Transport = &authTransport{next: client.transport}
+		return httpClient
 	}
+
+	if client.tlsConfig == nil && client.proxy == nil {
+		next := httpClient.Transport
+
+		if next == nil {
+			next = http.DefaultTransport
+		}
+
+		httpClient.Transport = &authTransport{next: next}
+
+		return httpClient
+	}
+
+	var transport *http.Transport
+
+	switch base := httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = base.Clone()
+	default:
+		// custom round trippers are kept as they are, like WithTransport
+		client.WarnF("http client transport %T is not *http.Transport, ignore TLS and proxy options", base)
+
+		httpClient.Transport = &authTransport{next: base}
+
+		return httpClient
+	}
+
+	if client.tlsConfig != nil {
+		transport.TLSClientConfig = client.tlsConfig
+	}
+
+	if client.proxy != nil {
+		transport.Proxy = client.proxy
+	}
+
+	httpClient.Transport = &authTransport{next: transport}
+
+	return httpClient
 }
 
 func (client *clientImpl) Call(path string, method string, args interface{}, reply interface{}, options ...Option) error {
+	return client.CallContext(context.Background(), path, method, args, reply, options...)
+}
+
+func (client *clientImpl) CallContext(ctx context.Context, path string, method string, args interface{}, reply interface{}, options ...Option) error {
 
 	pathnodes := strings.Split(path, "/")
 
@@ -73,150 +183,299 @@ func (client *clientImpl) Call(path string, method string, args interface{}, rep
 
 	path = strings.Join(pathnodes[:len(pathnodes)-1], "/")
 
-	return client.Service(path).Call(method, name, args, reply, options...)
+	return client.Service(path).CallContext(ctx, method, name, args, reply, options...)
 
 }
 
 type serviceImpl struct {
-	rootURL string
+	client  *clientImpl
 	path    string
+	breaker *breaker
 }
 
 func (client *clientImpl) Service(path string) Service {
 	return &serviceImpl{
-		rootURL: client.rootURL,
+		client:  client,
 		path:    path,
+		breaker: client.getBreaker(path),
 	}
 }
 
-func (service *serviceImpl) Call(method string, name string, args interface{}, reply interface{}, options ...Option) error {
+func (client *clientImpl) getBreaker(path string) *breaker {
+	if client.breakerSettings == nil {
+		return nil
+	}
 
-	url := fmt.Sprintf("%s/%s/%s", service.rootURL, service.path, name)
+	client.Lock()
+	defer client.Unlock()
 
-	checkedURL, err := service.checkURL(url)
+	cb, ok := client.breakers[path]
 
-	if err != nil {
-		return xerrors.Wrapf(err, "check url %s failed", url)
-	}
-
-	switch method {
-	case http.MethodGet:
-		return service.Get(checkedURL, args, reply, options...)
-	case http.MethodPost:
-		return service.Post(checkedURL, args, reply, options...)
-	case http.MethodDelete:
-		return service.Delete(checkedURL, args, reply, options...)
-	case http.MethodPut:
-		return service.Put(checkedURL, args, reply, options...)
-	default:
-		return xerrors.Wrapf(ErrMethod, "invalid method %s", method)
+	if !ok {
+		cb = newBreaker(path, *client.breakerSettings)
+		client.breakers[path] = cb
 	}
 
+	return cb
 }
 
-func (service *serviceImpl) Get(checkedURL string, args interface{}, reply interface{}, options ...Option) error {
-	r := resty.R().SetQueryParams(service.args2Map(args)).
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Accept", "application/json")
+func (service *serviceImpl) Call(method string, name string, args interface{}, reply interface{}, options ...Option) error {
+	return service.CallContext(context.Background(), method, name, args, reply, options...)
+}
 
-	for _, option := range options {
-		option(r.RawRequest)
-	}
+func (service *serviceImpl) CallContext(ctx context.Context, method string, name string, args interface{}, reply interface{}, options ...Option) error {
 
-	resp, err := r.Get(checkedURL)
+	builder := newRequestBuilder(method, options...)
 
-	if err != nil {
-		return xerrors.Wrapf(err, "network error")
+	request := &Request{
+		Path:    service.path,
+		Name:    name,
+		Args:    args,
+		Reply:   reply,
+		Builder: builder,
 	}
 
-	return service.checkResult(resp, reply)
+	_, err := service.client.invoker(service.invoke)(ctx, request)
+
+	return err
 }
 
-func (service *serviceImpl) Post(checkedURL string, args interface{}, reply interface{}, options ...Option) error {
-	r := resty.R().SetBody(service.args2JSON(args)).
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Accept", "application/json")
+// invoke the terminal invoker of interceptor chain, the call timeout is applied here
+// so that interceptors can change it
+func (service *serviceImpl) invoke(ctx context.Context, request *Request) (*Response, error) {
 
-	for _, option := range options {
-		option(r.RawRequest)
+	builder := request.Builder
+
+	if builder.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, builder.Timeout)
+		defer cancel()
+	} else if _, ok := ctx.Deadline(); !ok && service.client.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, service.client.timeout)
+		defer cancel()
 	}
 
-	resp, err := r.Post(checkedURL)
+	ctx = withAuths(ctx, builder.Auths)
 
-	if err != nil {
-		return xerrors.Wrapf(err, "network error")
+	var resp *Response
+
+	call := func() (err error) {
+		resp, err = service.call(ctx, builder, request.Name, request.Args, request.Reply)
+		return
 	}
 
-	return service.checkResult(resp, reply)
+	var err error
+
+	if service.breaker != nil {
+		err = service.breaker.Execute(call)
+	} else {
+		err = call()
+	}
+
+	if err != nil && service.client.fallback != nil && service.isFailure(err) {
+		return resp, service.client.fallback(service.path, builder.Method, request.Name, request.Args, request.Reply, err)
+	}
+
+	return resp, err
 }
 
-func (service *serviceImpl) Delete(checkedURL string, args interface{}, reply interface{}, options ...Option) error {
-	r := resty.R().SetQueryParams(service.args2Map(args)).
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Accept", "application/json")
+func (service *serviceImpl) isFailure(err error) bool {
+	if _, ok := err.(*BreakerOpenError); ok {
+		return true
+	}
 
-	for _, option := range options {
-		option(r.RawRequest)
+	if service.breaker != nil {
+		return service.breaker.settings.IsFailure(err)
 	}
 
-	resp, err := r.Delete(checkedURL)
+	return IsBreakerFailure(err)
+}
 
-	if err != nil {
-		return xerrors.Wrapf(err, "network error")
+func (service *serviceImpl) call(ctx context.Context, builder *RequestBuilder, name string, args interface{}, reply interface{}) (*Response, error) {
+	switch builder.Method {
+	case http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPut, http.MethodPatch:
+		return service.do(ctx, builder, name, args, reply)
+	default:
+		return nil, xerrors.Wrapf(ErrMethod, "invalid method %s", builder.Method)
 	}
 
-	return service.checkResult(resp, reply)
 }
 
-func (service *serviceImpl) Put(checkedURL string, args interface{}, reply interface{}, options ...Option) error {
-	r := resty.R().SetBody(service.args2JSON(args)).
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Accept", "application/json")
+func (service *serviceImpl) do(ctx context.Context, builder *RequestBuilder, name string, args interface{}, reply interface{}) (*Response, error) {
 
-	for _, option := range options {
-		option(r.RawRequest)
+	method := builder.Method
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Wrapf(err, "call %s/%s canceled", service.path, name)
+		}
+
+		r, err := service.newRequest(ctx, builder, args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		endpoint, err := service.client.balancer.pick()
+
+		if err != nil {
+			return nil, xerrors.Wrapf(err, "pick endpoint for %s/%s error", service.path, name)
+		}
+
+		url := fmt.Sprintf("%s/%s/%s", endpoint.url, service.path, name)
+
+		checkedURL, err := service.checkURL(url)
+
+		if err != nil {
+			service.client.balancer.done(endpoint, false)
+			return nil, xerrors.Wrapf(err, "check url %s failed", url)
+		}
+
+		resp, err := r.Execute(method, checkedURL)
+
+		status := 0
+
+		if err != nil {
+			resp = nil
+			err = xerrors.Wrapf(err, "network error")
+		} else {
+			status = resp.StatusCode()
+			err = service.checkResult(resp, reply, builder.Codec)
+		}
+
+		service.client.balancer.done(endpoint, service.client.balancer.failed(status, err))
+
+		if err == nil {
+			return newResponse(resp), nil
+		}
+
+		retry := service.client.retry
+
+		if retry == nil || attempt >= retry.MaxAttempts || !retry.retryable(method, r, resp, err) {
+			return newResponse(resp), err
+		}
+
+		timer := time.NewTimer(retry.backoff(attempt, resp))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return newResponse(resp), err
+		case <-timer.C:
+		}
 	}
+}
 
-	resp, err := r.Put(checkedURL)
+func (service *serviceImpl) newRequest(ctx context.Context, builder *RequestBuilder, args interface{}) (*resty.Request, error) {
+	r := service.client.rest.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", builder.Codec.ContentType()).
+		SetHeader("Accept", builder.Codec.ContentType())
 
-	if err != nil {
-		return xerrors.Wrapf(err, "network error")
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline) / time.Millisecond
+
+		if timeout < 1 {
+			timeout = 1
+		}
+
+		r.SetHeader(restrpc.HeaderTimeout, strconv.FormatInt(int64(timeout), 10))
 	}
 
-	return service.checkResult(resp, reply)
-}
+	if service.client.envelope != nil {
+		r.SetHeader(restrpc.HeaderEnvelope, service.client.envelope.Name())
+	}
 
-type result struct {
-	Code   int         `json:"code"`
-	ErrMsg string      `json:"errmsg"`
-	Result interface{} `json:"result"`
-}
+	if service.client.problemDetails {
+		r.SetHeader("Accept", builder.Codec.ContentType()+", "+restrpc.ContentTypeProblem)
+	}
 
-func (service *serviceImpl) checkResult(resp *resty.Response, reply interface{}) error {
+	// the header values are copied, the client and the builder are shared by concurrent calls and retries
+	for key, values := range service.client.headers {
+		r.Header[key] = append([]string(nil), values...)
+	}
 
-	var r result
+	for key, values := range builder.Header {
+		r.Header[key] = append([]string(nil), values...)
+	}
 
-	err := json.Unmarshal(resp.Body(), &r)
+	query := make(url.Values)
 
-	if err != nil {
-		return xerrors.Wrapf(restrpc.ErrInternal, "unmarshal %s err %s", resp.Body(), err)
+	if builder.Method == http.MethodGet || builder.Method == http.MethodDelete {
+		if err := writeQuery(args, "", query); err != nil {
+			return nil, xerrors.Wrapf(err, "encode query args error")
+		}
+	} else {
+		body, err := builder.Codec.Marshal(args)
+
+		if err != nil {
+			return nil, xerrors.Wrapf(err, "encode body args error")
+		}
+
+		if service.client.compressMinSize > 0 && len(body) >= service.client.compressMinSize {
+			if body, err = gzipBody(body); err != nil {
+				return nil, err
+			}
+
+			r.SetHeader("Content-Encoding", "gzip")
+		}
+
+		r.SetBody(body)
 	}
 
-	if resp.StatusCode() != http.StatusOK {
-		return xerrors.Wrapf(apierr.New(r.Code, r.ErrMsg), "apierr: %s", resp.Body())
+	for key, values := range builder.Query {
+		query[key] = append(query[key], values...)
 	}
 
-	if r.Result == nil {
-		return xerrors.Wrapf(restrpc.ErrInternal, "result not found: %s", resp.Body())
+	r.SetMultiValueQueryParams(query)
+
+	// cookies are sent in a single Cookie header like http.Request.AddCookie
+	if len(builder.Cookies) > 0 {
+		var cookies []string
+
+		if cookie := r.Header.Get("Cookie"); cookie != "" {
+			cookies = append(cookies, cookie)
+		}
+
+		for _, cookie := range builder.Cookies {
+			cookies = append(cookies, (&http.Cookie{Name: cookie.Name, Value: cookie.Value}).String())
+		}
+
+		r.Header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	return r, nil
+}
+
+// checkResult decode the response with the envelope negotiated by the server, a null result leaves reply untouched
+func (service *serviceImpl) checkResult(resp *resty.Response, reply interface{}, codec Codec) error {
+
+	if restrpc.IsProblem(resp.Header().Get("Content-Type")) {
+		problem, err := restrpc.ParseProblem(resp.Body())
+
+		if err != nil {
+			return err
+		}
+
+		return xerrors.Wrapf(problem, "apierr: %s", resp.Body())
 	}
 
-	buff, err := json.Marshal(r.Result)
+	raw, err := service.client.responseEnvelope(resp).Decode(resp.StatusCode(), resp.Body())
 
 	if err != nil {
-		return xerrors.Wrapf(restrpc.ErrInternal, "marshal result %v err %s", r.Result, err)
+		if _, ok := err.(apierr.APIErr); ok {
+			return xerrors.Wrapf(err, "apierr: %s", resp.Body())
+		}
+
+		return err
+	}
+
+	if raw == nil || reply == nil {
+		return nil
 	}
 
-	if err := json.Unmarshal(buff, reply); err != nil {
+	if err := codec.Unmarshal(raw, reply); err != nil {
 		return xerrors.Wrapf(restrpc.ErrInternal, "unmarshal %s err %s", resp.Body(), err)
 	}
 
@@ -234,11 +493,3 @@ func (service *serviceImpl) checkURL(s string) (string, error) {
 
 	return u.String(), nil
 }
-
-func (service *serviceImpl) args2Map(args interface{}) map[string]string {
-	return nil
-}
-
-func (service *serviceImpl) args2JSON(args interface{}) string {
-	return ""
-}
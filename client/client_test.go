@@ -3,6 +3,8 @@ package client
 import (
 	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 type testA interface {
@@ -17,8 +19,17 @@ func TestImplInterface(t *testing.T) {
 	mt := it.Method(0).Type
 
 	fn := reflect.MakeFunc(mt, func(args []reflect.Value) []reflect.Value {
-		return nil
+		return []reflect.Value{reflect.Zero(mt.Out(0))}
+	})
+
+	// reflect can not implement an interface, only a function of the method type can be built
+	require.Panics(t, func() {
+		reflect.ValueOf(&i).Elem().Set(fn)
 	})
 
-	reflect.ValueOf(&i).Elem().Set(fn)
+	var hello func(string) error
+
+	reflect.ValueOf(&hello).Elem().Set(fn)
+
+	require.NoError(t, hello("world"))
 }
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Codec call body codec
+type Codec interface {
+	ContentType() string
+	Marshal(args interface{}) ([]byte, error)
+	Unmarshal(data []byte, reply interface{}) error
+}
+
+// JSONCodec default codec, encode args with the restrpc parameter names
+type JSONCodec struct {
+}
+
+// ContentType .
+func (codec *JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+// Marshal .
+func (codec *JSONCodec) Marshal(args interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+
+	if err := writeJSON(args, &buff); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+// Unmarshal .
+func (codec *JSONCodec) Unmarshal(data []byte, reply interface{}) error {
+	return json.Unmarshal(data, reply)
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/dynamicgo/xerrors"
+)
+
+// WithCompression gzip POST/PUT request bodies not smaller than minSize bytes
+func WithCompression(minSize int) ClientOption {
+	return func(client *clientImpl) {
+		client.compressMinSize = minSize
+	}
+}
+
+func gzipBody(body []byte) ([]byte, error) {
+	var buff bytes.Buffer
+
+	writer := gzip.NewWriter(&buff)
+
+	if _, err := writer.Write(body); err != nil {
+		return nil, xerrors.Wrapf(err, "gzip body error")
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, xerrors.Wrapf(err, "gzip body error")
+	}
+
+	return buff.Bytes(), nil
+}
+
+// decompressTransport asks for gzip/deflate replies and decodes them, so that resty, interceptors
+// and codecs always see the plain body whatever the underlying transport is
+type decompressTransport struct {
+	next http.RoundTripper
+}
+
+func (transport *decompressTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Header.Get("Accept-Encoding") == "" {
+		request = request.Clone(request.Context())
+		request.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+
+	resp, err := transport.next.RoundTrip(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.ReadCloser
+
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		reader, err = gzip.NewReader(resp.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(resp.Body)
+	default:
+		return resp, nil
+	}
+
+	if err != nil {
+		resp.Body.Close()
+		return nil, xerrors.Wrapf(err, "decode %s response error", resp.Header.Get("Content-Encoding"))
+	}
+
+	resp.Body = &decodedBody{ReadCloser: reader, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return resp, nil
+}
+
+type decodedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (body *decodedBody) Close() error {
+	body.ReadCloser.Close()
+	return body.raw.Close()
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dynamicgo/restrpc/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	testServer := httptest.NewServer(server.New(server.WithCompression(server.CompressionConfig{MinSize: 64})).Handle("/test", &echoService{},
+		func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+			requests <- r
+			next.ServeHTTP(w, r)
+		}))
+	defer testServer.Close()
+
+	var encodings []string
+
+	transport := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		resp, err := http.DefaultTransport.RoundTrip(request)
+
+		if err == nil {
+			encodings = append(encodings, resp.Header.Get("Content-Encoding"))
+		}
+
+		return resp, err
+	})
+
+	client := New(testServer.URL, WithTransport(transport), WithCompression(32))
+
+	var reply echoResult
+
+	require.NoError(t, client.Call("/test/echo", http.MethodPost, &echoParam{Name: strings.Repeat("a", 128), Count: 3}, &reply))
+
+	request := <-requests
+
+	require.Equal(t, "gzip", request.Header.Get("Content-Encoding"))
+	require.Equal(t, 3, reply.Count)
+	require.Equal(t, []string{"gzip"}, encodings)
+
+	// small bodies and replies are sent as is
+	require.NoError(t, client.Call("/test/echo", http.MethodPost, &echoParam{Count: 4}, &reply))
+
+	request = <-requests
+
+	require.Equal(t, "", request.Header.Get("Content-Encoding"))
+	require.Equal(t, 4, reply.Count)
+	require.Equal(t, []string{"gzip", ""}, encodings)
+}
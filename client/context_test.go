@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/restrpc/server"
+	"github.com/stretchr/testify/require"
+)
+
+type deadlineService struct {
+}
+
+type deadlineParam struct {
+}
+
+type deadlineResult struct {
+	HasDeadline bool `json:"hasDeadline"`
+}
+
+func (service *deadlineService) GetDeadline(ctx context.Context, p *deadlineParam, r *deadlineResult) error {
+	_, r.HasDeadline = ctx.Deadline()
+	return nil
+}
+
+func (service *deadlineService) GetSleep(ctx context.Context, p *deadlineParam, r *deadlineResult) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+func TestCallContextDeadline(t *testing.T) {
+	testServer := httptest.NewServer(server.New().Handle("/test", &deadlineService{}))
+	defer testServer.Close()
+
+	var reply deadlineResult
+
+	require.NoError(t, New(testServer.URL).Call("/test/deadline", http.MethodGet, nil, &reply))
+	require.False(t, reply.HasDeadline)
+
+	require.NoError(t, New(testServer.URL, WithTimeout(time.Second)).Call("/test/deadline", http.MethodGet, nil, &reply))
+	require.True(t, reply.HasDeadline)
+}
+
+func TestCallContextCancel(t *testing.T) {
+	testServer := httptest.NewServer(server.New().Handle("/test", &deadlineService{}))
+	defer testServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var reply deadlineResult
+
+	start := time.Now()
+
+	require.Error(t, New(testServer.URL).CallContext(ctx, "/test/sleep", http.MethodGet, nil, &reply))
+	require.True(t, time.Since(start) < time.Second)
+}
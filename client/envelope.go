@@ -0,0 +1,38 @@
+package client
+
+import (
+	"github.com/dynamicgo/restrpc"
+	"github.com/go-resty/resty"
+)
+
+// WithEnvelope ask the server for the response envelope with restrpc.HeaderEnvelope,
+// e.g. restrpc.DataErrorEnvelope{}, custom envelopes must be registered on both sides
+func WithEnvelope(envelope restrpc.Envelope) ClientOption {
+	return func(client *clientImpl) {
+		client.envelope = envelope
+	}
+}
+
+// responseEnvelope returns the envelope echoed by the server, servers without envelope
+// negotiation are decoded with the configured envelope, by default the classic one
+func (client *clientImpl) responseEnvelope(resp *resty.Response) restrpc.Envelope {
+	name := resp.Header().Get(restrpc.HeaderEnvelope)
+
+	if client.envelope != nil && (name == "" || name == client.envelope.Name()) {
+		return client.envelope
+	}
+
+	if envelope, ok := restrpc.LookupEnvelope(name); ok {
+		return envelope
+	}
+
+	return restrpc.ClassicEnvelope{}
+}
+
+// WithProblemDetails accept restrpc.ContentTypeProblem error responses, problem documents
+// are always parsed into *restrpc.Problem errors which implement apierr.APIErr
+func WithProblemDetails() ClientOption {
+	return func(client *clientImpl) {
+		client.problemDetails = true
+	}
+}
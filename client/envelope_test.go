@@ -0,0 +1,92 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/restrpc/server"
+	"github.com/dynamicgo/xerrors/apierr"
+	"github.com/stretchr/testify/require"
+)
+
+var errEnvelope = apierr.New(-7, "boom")
+
+type envelopeService struct {
+	echoService
+}
+
+func (service *envelopeService) GetFail(p *echoParam, r *echoResult) error {
+	return errEnvelope
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	testServer := httptest.NewServer(server.New(server.WithEnvelope(restrpc.RawEnvelope{})).Handle("/test", &envelopeService{}))
+	defer testServer.Close()
+
+	// the client decodes the envelope echoed by the server, whether it asked for one or not
+	for _, options := range [][]ClientOption{
+		nil,
+		{WithEnvelope(restrpc.ClassicEnvelope{})},
+		{WithEnvelope(restrpc.DataErrorEnvelope{})},
+	} {
+		client := New(testServer.URL, options...)
+
+		var reply echoResult
+
+		require.NoError(t, client.Call("/test/echo", http.MethodPost, &echoParam{Name: "b", Count: 2}, &reply))
+		require.Equal(t, echoResult{Name: "b", Count: 2}, reply)
+
+		err := client.Call("/test/fail", http.MethodGet, &echoParam{}, &reply)
+		require.Error(t, err)
+		require.Equal(t, -7, apierr.As(err, nil).Code())
+		require.Equal(t, "boom", apierr.As(err, nil).Error())
+	}
+}
+
+func TestEnvelopeDecode(t *testing.T) {
+	for _, envelope := range []restrpc.Envelope{restrpc.ClassicEnvelope{}, restrpc.RawEnvelope{}, restrpc.DataErrorEnvelope{}} {
+		success, err := json.Marshal(envelope.Success(nil))
+		require.NoError(t, err)
+
+		raw, err := envelope.Decode(http.StatusOK, success)
+		require.NoError(t, err, envelope.Name())
+		require.Nil(t, raw, envelope.Name())
+
+		failure, err := json.Marshal(envelope.Failure(errEnvelope))
+		require.NoError(t, err)
+
+		_, err = envelope.Decode(http.StatusBadRequest, failure)
+		require.Equal(t, -7, apierr.As(err, nil).Code(), envelope.Name())
+
+		_, err = envelope.Decode(http.StatusNotFound, []byte("404 page not found"))
+		require.Equal(t, restrpc.ErrInternal.Code(), apierr.As(err, nil).Code(), envelope.Name())
+	}
+
+	// bodies not matching the envelope are not mistaken for null results
+	for envelope, body := range map[restrpc.Envelope]string{
+		restrpc.ClassicEnvelope{}:   `{"name":"a"}`,
+		restrpc.DataErrorEnvelope{}: `{"result":{"name":"a"}}`,
+		restrpc.RawEnvelope{}:       ``,
+	} {
+		_, err := envelope.Decode(http.StatusOK, []byte(body))
+		require.Equal(t, restrpc.ErrInternal.Code(), apierr.As(err, nil).Code(), envelope.Name())
+	}
+
+	// an older classic server without envelope negotiation
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":{"name":"a","count":1}}`))
+	}))
+	defer testServer.Close()
+
+	var reply echoResult
+
+	err := New(testServer.URL, WithEnvelope(restrpc.DataErrorEnvelope{})).Call("/test/echo", http.MethodGet, nil, &reply)
+	require.Equal(t, restrpc.ErrInternal.Code(), apierr.As(err, nil).Code())
+
+	require.NoError(t, New(testServer.URL).Call("/test/echo", http.MethodGet, nil, &reply))
+	require.Equal(t, echoResult{Name: "a", Count: 1}, reply)
+}
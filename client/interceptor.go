@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-resty/resty"
+)
+
+// Request call request passed through the interceptor chain
+type Request struct {
+	Path    string          // service path
+	Name    string          // service method name
+	Args    interface{}     // call args
+	Reply   interface{}     // call reply
+	Builder *RequestBuilder // call options, interceptors can modify it before calling next
+}
+
+// Response call response, nil if the call failed without http response
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Invoker invoke call request
+type Invoker func(ctx context.Context, request *Request) (*Response, error)
+
+// Interceptor client interceptor wraps every Service.Call
+type Interceptor func(ctx context.Context, request *Request, next Invoker) (*Response, error)
+
+// WithInterceptor add client interceptors, the first one is the outermost
+func WithInterceptor(interceptors ...Interceptor) ClientOption {
+	return func(client *clientImpl) {
+		client.interceptors = append(client.interceptors, interceptors...)
+	}
+}
+
+func (client *clientImpl) invoker(invoker Invoker) Invoker {
+
+	next := invoker
+
+	for i := len(client.interceptors); i > 0; i-- {
+		interceptor := client.interceptors[i-1]
+		inner := next
+
+		next = func(ctx context.Context, request *Request) (*Response, error) {
+			return interceptor(ctx, request, inner)
+		}
+	}
+
+	return next
+}
+
+func newResponse(resp *resty.Response) *Response {
+	if resp == nil {
+		return nil
+	}
+
+	return &Response{
+		StatusCode: resp.StatusCode(),
+		Header:     resp.Header(),
+		Body:       resp.Body(),
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptorChain(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	testServer := newEchoServer(requests)
+	defer testServer.Close()
+
+	var trace []string
+
+	tracer := func(name string) Interceptor {
+		return func(ctx context.Context, request *Request, next Invoker) (*Response, error) {
+			trace = append(trace, name)
+			request.Builder.Header.Add("X-Trace", name)
+			resp, err := next(ctx, request)
+			trace = append(trace, name)
+			return resp, err
+		}
+	}
+
+	client := New(testServer.URL, WithInterceptor(tracer("a"), tracer("b")))
+
+	var reply echoResult
+
+	require.NoError(t, client.Call("/test/echo", http.MethodGet, &echoParam{Count: 1}, &reply))
+
+	require.Equal(t, []string{"a", "b", "b", "a"}, trace)
+	require.Equal(t, []string{"a", "b"}, (<-requests).Header["X-Trace"])
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	injected := errors.New("injected fault")
+
+	client := New("http://127.0.0.1:1", WithInterceptor(func(ctx context.Context, request *Request, next Invoker) (*Response, error) {
+		return nil, injected
+	}))
+
+	var reply echoResult
+
+	require.Equal(t, injected, client.Call("/test/echo", http.MethodGet, nil, &reply))
+}
+
+func TestInterceptorTimeout(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	testServer := newEchoServer(requests)
+	defer testServer.Close()
+
+	client := New(testServer.URL, WithTimeout(time.Minute), WithInterceptor(func(ctx context.Context, request *Request, next Invoker) (*Response, error) {
+		request.Builder.Timeout = time.Second
+		return next(ctx, request)
+	}))
+
+	var reply echoResult
+
+	require.NoError(t, client.Call("/test/echo", http.MethodGet, &echoParam{Count: 1}, &reply))
+
+	timeout, err := strconv.ParseInt((<-requests).Header.Get("X-Restrpc-Timeout"), 10, 64)
+
+	require.NoError(t, err)
+	require.True(t, timeout > 0 && timeout <= 1000, "timeout %d", timeout)
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/restrpc/server"
+	"github.com/dynamicgo/xerrors/apierr"
+	"github.com/stretchr/testify/require"
+)
+
+type problemParam struct {
+	ID    string `rest:"required"`
+	Count int    `rest:"n"`
+}
+
+type problemService struct {
+}
+
+func (service *problemService) GetFail(p *problemParam, r *echoResult) error {
+	return errEnvelope
+}
+
+func (service *problemService) PutConflict(p *problemParam, r *echoResult) error {
+	return &restrpc.Problem{
+		Type:    "https://example.com/problems/conflict",
+		Status:  http.StatusConflict,
+		ErrCode: -9,
+		Detail:  "version conflict",
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	testServer := httptest.NewServer(server.New(server.WithProblemDetails()).Handle("/test", &problemService{}))
+	defer testServer.Close()
+
+	client := New(testServer.URL)
+
+	err := client.Call("/test/conflict", http.MethodPut, &problemParam{ID: "1"}, &echoResult{})
+	require.Equal(t, -9, apierr.As(err, nil).Code())
+
+	var declared *restrpc.Problem
+
+	require.True(t, errors.As(err, &declared))
+	require.Equal(t, http.StatusConflict, declared.Status)
+	require.Equal(t, "https://example.com/problems/conflict", declared.Type)
+	require.Equal(t, "version conflict", declared.Error())
+}
+
+func TestProblemNegotiation(t *testing.T) {
+	testServer := httptest.NewServer(server.New().Handle("/test", &problemService{}))
+	defer testServer.Close()
+
+	err := New(testServer.URL, WithProblemDetails()).Call("/test/fail", http.MethodGet, &echoParam{}, &echoResult{})
+
+	var problem *restrpc.Problem
+
+	require.True(t, errors.As(err, &problem), "%v", err)
+	require.Equal(t, []*restrpc.FieldError{{Field: "id", Detail: "expect param id"}}, problem.Errors)
+
+	err = New(testServer.URL).Call("/test/fail", http.MethodGet, &problemParam{ID: "1"}, &echoResult{})
+	require.False(t, errors.As(err, &problem))
+	require.Equal(t, -7, apierr.As(err, nil).Code())
+}
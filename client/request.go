@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RequestBuilder collects call options before the request is created,
+// it is built once per call and reused by every retry attempt
+type RequestBuilder struct {
+	Method  string
+	Header  http.Header
+	Query   url.Values
+	Cookies []*http.Cookie
+	Timeout time.Duration // per call timeout, overrides client default timeout
+	Codec   Codec
+	Auths   []Auth // applied to the outgoing *http.Request by client transport
+}
+
+// Option call option
+type Option func(builder *RequestBuilder)
+
+func newRequestBuilder(method string, options ...Option) *RequestBuilder {
+	builder := &RequestBuilder{
+		Method: method,
+		Header: make(http.Header),
+		Query:  make(url.Values),
+		Codec:  &JSONCodec{},
+	}
+
+	for _, option := range options {
+		option(builder)
+	}
+
+	return builder
+}
+
+// WithAuth add auth option
+func WithAuth(auth Auth) Option {
+	return func(builder *RequestBuilder) {
+		builder.Auths = append(builder.Auths, auth)
+	}
+}
+
+// WithJWToken .
+func WithJWToken(token string) Option {
+	return func(builder *RequestBuilder) {
+		builder.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+}
+
+// WithIdempotencyKey set idempotency key header, which make POST request retryable
+func WithIdempotencyKey(key string) Option {
+	return func(builder *RequestBuilder) {
+		builder.Header.Set(HeaderIdempotencyKey, key)
+	}
+}
+
+// WithRequestHeader add request header
+func WithRequestHeader(key string, value string) Option {
+	return func(builder *RequestBuilder) {
+		builder.Header.Add(key, value)
+	}
+}
+
+// WithQuery add query value
+func WithQuery(key string, value string) Option {
+	return func(builder *RequestBuilder) {
+		builder.Query.Add(key, value)
+	}
+}
+
+// WithCookie add request cookie
+func WithCookie(cookie *http.Cookie) Option {
+	return func(builder *RequestBuilder) {
+		builder.Cookies = append(builder.Cookies, cookie)
+	}
+}
+
+// WithCallTimeout set call timeout
+func WithCallTimeout(timeout time.Duration) Option {
+	return func(builder *RequestBuilder) {
+		builder.Timeout = timeout
+	}
+}
+
+// WithCodec set body codec
+func WithCodec(codec Codec) Option {
+	return func(builder *RequestBuilder) {
+		builder.Codec = codec
+	}
+}
+
+type authKey struct{}
+
+// authTransport applies the call Auths to the raw request created by resty
+type authTransport struct {
+	next http.RoundTripper
+}
+
+func withAuths(ctx context.Context, auths []Auth) context.Context {
+	if len(auths) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, authKey{}, auths)
+}
+
+func (transport *authTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	auths, ok := request.Context().Value(authKey{}).([]Auth)
+
+	if !ok {
+		return transport.next.RoundTrip(request)
+	}
+
+	request = request.Clone(request.Context())
+
+	for _, auth := range auths {
+		auth.Handle(request)
+	}
+
+	return transport.next.RoundTrip(request)
+}
@@ -0,0 +1,422 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/restrpc/server"
+	"github.com/dynamicgo/restrpc/validator"
+	"github.com/dynamicgo/xerrors/apierr"
+	"github.com/stretchr/testify/require"
+)
+
+type echoService struct {
+}
+
+type echoParam struct {
+	Name  string
+	Count int `rest:"n"`
+}
+
+type echoResult struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (service *echoService) GetEcho(p *echoParam, r *echoResult) error {
+	r.Name = p.Name
+	r.Count = p.Count
+	return nil
+}
+
+func (service *echoService) PostEcho(p *echoParam, r *echoResult) error {
+	return service.GetEcho(p, r)
+}
+
+type headerAuth struct {
+}
+
+func (auth *headerAuth) Handle(request *http.Request) {
+	request.Header.Set("X-Auth", request.Method)
+}
+
+func newEchoServer(requests chan *http.Request) *httptest.Server {
+	return httptest.NewServer(server.New().Handle("/test", &echoService{}, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		requests <- r
+		next.ServeHTTP(w, r)
+	}))
+}
+
+func requireAPICode(t *testing.T, err error, code int) {
+	require.Error(t, err)
+	require.Equal(t, code, apierr.As(err, nil).Code())
+}
+
+func TestWithJWToken(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	testServer := newEchoServer(requests)
+	defer testServer.Close()
+
+	var reply echoResult
+
+	require.NoError(t, New(testServer.URL).Call("/test/echo", http.MethodGet, &echoParam{Name: "hello", Count: 2}, &reply, WithJWToken("token")))
+
+	request := <-requests
+
+	require.Equal(t, "Bearer token", request.Header.Get("Authorization"))
+	require.Equal(t, echoResult{Name: "hello", Count: 2}, reply)
+}
+
+func TestCallOptions(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	testServer := newEchoServer(requests)
+	defer testServer.Close()
+
+	var reply echoResult
+
+	err := New(testServer.URL).Call("/test/echo", http.MethodPost, &echoParam{Name: "hello", Count: 3}, &reply,
+		WithAuth(&headerAuth{}),
+		WithRequestHeader("X-Test", "1"),
+		WithQuery("q", "2"),
+		WithCookie(&http.Cookie{Name: "session", Value: "3"}),
+		WithCookie(&http.Cookie{Name: "lang", Value: "en"}),
+		WithIdempotencyKey("4"),
+		WithCallTimeout(time.Second),
+	)
+
+	require.NoError(t, err)
+
+	request := <-requests
+
+	cookie, err := request.Cookie("session")
+
+	require.NoError(t, err)
+	require.Equal(t, "3", cookie.Value)
+	require.Len(t, request.Cookies(), 2)
+
+	cookie, err = request.Cookie("lang")
+
+	require.NoError(t, err)
+	require.Equal(t, "en", cookie.Value)
+	require.Equal(t, []string{"session=3; lang=en"}, request.Header["Cookie"])
+	require.Equal(t, http.MethodPost, request.Header.Get("X-Auth"))
+	require.Equal(t, "1", request.Header.Get("X-Test"))
+	require.Equal(t, "2", request.URL.Query().Get("q"))
+	require.Equal(t, "4", request.Header.Get(HeaderIdempotencyKey))
+	require.NotEmpty(t, request.Header.Get("X-Restrpc-Timeout"))
+	require.Equal(t, 3, reply.Count)
+}
+
+func TestCallHeadersNotShared(t *testing.T) {
+	client := New("http://localhost", WithHeader("Cookie", "a=1"), WithHeader("X-Base", "1"), WithHeader("X-Base", "2"), WithHeader("X-Base", "3")).(*clientImpl)
+	defer client.Close()
+
+	service := client.Service("test").(*serviceImpl)
+
+	builder := newRequestBuilder(http.MethodGet, WithRequestHeader("X-Call", "1"), WithCookie(&http.Cookie{Name: "session", Value: "x"}))
+
+	first, err := service.newRequest(context.Background(), builder, nil)
+	require.NoError(t, err)
+
+	first.Header.Add("X-Base", "first")
+	first.Header.Add("X-Call", "first")
+
+	second, err := service.newRequest(context.Background(), newRequestBuilder(http.MethodGet, WithCookie(&http.Cookie{Name: "session", Value: "y"})), nil)
+	require.NoError(t, err)
+
+	second.Header.Add("X-Base", "second")
+
+	require.Equal(t, []string{"a=1; session=x"}, first.Header["Cookie"])
+	require.Equal(t, []string{"a=1; session=y"}, second.Header["Cookie"])
+	require.Equal(t, []string{"1", "2", "3", "first"}, first.Header["X-Base"])
+	require.Equal(t, []string{"1", "2", "3"}, client.headers["X-Base"])
+	require.Equal(t, []string{"a=1"}, client.headers["Cookie"])
+	require.Equal(t, []string{"1"}, builder.Header["X-Call"])
+}
+
+type listItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type listParam struct {
+	IDs   []int64    `rest:"ids" json:"ids"`
+	Items []listItem `json:"items"`
+}
+
+type listService struct {
+}
+
+func (service *listService) GetList(p *listParam, r *listParam) error {
+	*r = *p
+	return nil
+}
+
+func TestSliceQuery(t *testing.T) {
+	query := make(url.Values)
+
+	require.NoError(t, writeQuery(&listParam{IDs: []int64{1, 2}, Items: []listItem{{Name: "x", Count: 1}}}, "", query))
+	require.Equal(t, url.Values{"ids": {"1", "2"}, "items.0.name": {"x"}, "items.0.count": {"1"}}, query)
+
+	testServer := httptest.NewServer(server.New().Handle("/test", &listService{}))
+	defer testServer.Close()
+
+	param := listParam{IDs: []int64{1, 2}, Items: []listItem{{Name: "x", Count: 1}, {Name: "y", Count: 2}}}
+
+	var reply listParam
+
+	require.NoError(t, New(testServer.URL).Call("/test/list", http.MethodGet, &param, &reply))
+	require.Equal(t, param, reply)
+}
+
+type timeParam struct {
+	Day      time.Time     `rest:"day,layout=2006-01-02"`
+	At       time.Time     `rest:"at,unixms"`
+	Timeout  time.Duration `rest:"timeout"`
+	IP       net.IP        `rest:"ip"`
+	Callback *url.URL      `rest:"callback"`
+}
+
+type timeResult struct {
+	Day      string `json:"day"`
+	At       int64  `json:"at"`
+	Timeout  string `json:"timeout"`
+	IP       string `json:"ip"`
+	Callback string `json:"callback"`
+}
+
+type timeService struct {
+}
+
+func (service *timeService) GetTime(p *timeParam, r *timeResult) error {
+	r.Day = p.Day.Format("2006-01-02")
+	r.At = p.At.UnixNano() / int64(time.Millisecond)
+	r.Timeout = p.Timeout.String()
+	r.IP = p.IP.String()
+	r.Callback = p.Callback.String()
+	return nil
+}
+
+func (service *timeService) PostTime(p *timeParam, r *timeResult) error {
+	return service.GetTime(p, r)
+}
+
+func TestTextParams(t *testing.T) {
+	testServer := httptest.NewServer(server.New().Handle("/test", &timeService{}))
+	defer testServer.Close()
+
+	callback, err := url.Parse("https://example.com/hook?id=1")
+	require.NoError(t, err)
+
+	param := &timeParam{
+		Day:      time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		At:       time.Unix(1577934245, 500*int64(time.Millisecond)),
+		Timeout:  1500 * time.Millisecond,
+		IP:       net.ParseIP("10.0.0.1"),
+		Callback: callback,
+	}
+
+	expected := timeResult{
+		Day:      "2020-01-02",
+		At:       1577934245500,
+		Timeout:  "1.5s",
+		IP:       "10.0.0.1",
+		Callback: "https://example.com/hook?id=1",
+	}
+
+	client := New(testServer.URL)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		var reply timeResult
+
+		require.NoError(t, client.Call("/test/time", method, param, &reply))
+		require.Equal(t, expected, reply, method)
+	}
+}
+
+type pageParam struct {
+	Limit int    `rest:"limit,default=20" json:"limit"`
+	Order string `rest:"order,default=asc" json:"order"`
+}
+
+type pageService struct {
+}
+
+func (service *pageService) GetPage(p *pageParam, r *pageParam) error {
+	*r = *p
+	return nil
+}
+
+func (service *pageService) PostPage(p *pageParam, r *pageParam) error {
+	return service.GetPage(p, r)
+}
+
+func TestDefaultParams(t *testing.T) {
+	query := make(url.Values)
+
+	require.NoError(t, writeQuery(&pageParam{Limit: 20, Order: "desc"}, "", query))
+	require.Equal(t, url.Values{"order": {"desc"}}, query)
+
+	body, err := (&JSONCodec{}).Marshal(&pageParam{Limit: 5, Order: "asc"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"limit":5}`, string(body))
+
+	s := server.New().Handle("/test", &pageService{})
+
+	testServer := httptest.NewServer(s)
+	defer testServer.Close()
+
+	client := New(testServer.URL)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		var reply pageParam
+
+		require.NoError(t, client.Call("/test/page", method, &pageParam{Limit: 20, Order: "desc"}, &reply))
+		require.Equal(t, 20, reply.Limit, method)
+	}
+
+	var reply pageParam
+
+	require.NoError(t, client.Call("/test/page", http.MethodGet, &pageParam{Limit: 1, Order: "asc"}, &reply))
+	require.Equal(t, pageParam{Limit: 1, Order: "asc"}, reply)
+
+	routes := s.Routes()
+
+	require.NotEmpty(t, routes)
+	require.Equal(t, "limit", routes[0].Parameters[0].Name)
+	require.Equal(t, "20", *routes[0].Parameters[0].Default)
+}
+
+type PageQuery struct {
+	Limit int `rest:"limit" json:"limit"`
+}
+
+type searchParam struct {
+	PageQuery
+	Keyword string `rest:"q" json:"q"`
+	secret  string
+}
+
+type SortQuery struct {
+	Limit int `rest:"limit"`
+}
+
+type collisionParam struct {
+	Other struct {
+		PageQuery
+		SortQuery
+	}
+}
+
+type searchService struct {
+}
+
+func (service *searchService) GetSearch(p *searchParam, r *searchParam) error {
+	*r = *p
+	return nil
+}
+
+type collisionService struct {
+}
+
+func (service *collisionService) GetCollision(p *collisionParam, r *searchParam) error {
+	return nil
+}
+
+func TestEmbeddedParams(t *testing.T) {
+	query := make(url.Values)
+
+	require.NoError(t, writeQuery(&searchParam{PageQuery: PageQuery{Limit: 3}, Keyword: "x", secret: "s"}, "", query))
+	require.Equal(t, url.Values{"limit": {"3"}, "q": {"x"}}, query)
+
+	s := server.New().Handle("/test", &searchService{})
+
+	testServer := httptest.NewServer(s)
+	defer testServer.Close()
+
+	var reply searchParam
+
+	require.NoError(t, New(testServer.URL).Call("/test/search", http.MethodGet, &searchParam{PageQuery: PageQuery{Limit: 3}, Keyword: "x"}, &reply))
+	require.Equal(t, searchParam{PageQuery: PageQuery{Limit: 3}, Keyword: "x"}, reply)
+
+	// methods with colliding parameter names are rejected at registration
+	require.Panics(t, func() {
+		server.New().Handle("/test", &collisionService{})
+	})
+}
+
+type patchParam struct {
+	ID       string `rest:"required"`
+	Name     *string
+	Limit    int `rest:"limit,default=20"`
+	Presence validator.PresenceSet
+}
+
+type patchResult struct {
+	Presence validator.PresenceSet `json:"presence"`
+}
+
+type patchService struct {
+}
+
+func (service *patchService) PatchUser(p *patchParam, r *patchResult) error {
+	r.Presence = p.Presence
+	return nil
+}
+
+func TestPatchParams(t *testing.T) {
+	body, err := (&JSONCodec{}).Marshal(&patchParam{
+		ID:       "1",
+		Limit:    20,
+		Presence: validator.PresenceSet{"name": validator.Null, "limit": validator.Present},
+	})
+
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"1","name":null,"limit":20}`, string(body))
+
+	testServer := httptest.NewServer(server.New().Handle("/test", &patchService{}))
+	defer testServer.Close()
+
+	client := New(testServer.URL)
+
+	var reply patchResult
+
+	require.NoError(t, client.Call("/test/user", http.MethodPatch, &patchParam{
+		ID:       "1",
+		Limit:    20,
+		Presence: validator.PresenceSet{"name": validator.Null},
+	}, &reply))
+
+	require.Equal(t, validator.PresenceSet{"id": validator.Present, "name": validator.Null}, reply.Presence)
+
+	name := "x"
+
+	require.NoError(t, client.Call("/test/user", http.MethodPatch, &patchParam{ID: "1", Name: &name, Limit: 20}, &reply))
+	require.Equal(t, validator.PresenceSet{"id": validator.Present, "name": validator.Present}, reply.Presence)
+}
+
+type envDefaultParam struct {
+	Limit int `rest:"limit,default=${TEST_RESTRPC_PAGE_SIZE}"`
+}
+
+func TestEnvironmentDefaultParams(t *testing.T) {
+	os.Setenv("TEST_RESTRPC_PAGE_SIZE", "20")
+	defer os.Unsetenv("TEST_RESTRPC_PAGE_SIZE")
+
+	query := make(url.Values)
+
+	require.NoError(t, writeQuery(&envDefaultParam{Limit: 20}, "", query))
+	require.Equal(t, url.Values{"limit": {"20"}}, query)
+
+	body, err := (&JSONCodec{}).Marshal(&envDefaultParam{Limit: 20})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"limit":20}`, string(body))
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dynamicgo/restrpc/registry"
+	"github.com/dynamicgo/slf4go"
+	"github.com/dynamicgo/xerrors"
+)
+
+type fileResolver struct {
+	slf4go.Logger
+	path     string
+	service  string
+	interval time.Duration
+}
+
+// NewFileResolver create resolver reading service endpoints from the JSON/YAML registry file,
+// the file is polled every interval for changes
+func NewFileResolver(path string, service string, interval time.Duration) Resolver {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	return &fileResolver{
+		Logger:   slf4go.Get("file-resolver"),
+		path:     path,
+		service:  service,
+		interval: interval,
+	}
+}
+
+func (resolver *fileResolver) Resolve(ctx context.Context) ([]Endpoint, error) {
+	services, err := registry.Load(resolver.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []Endpoint
+
+	for _, entry := range services[resolver.service] {
+		endpoints = append(endpoints, Endpoint{
+			URL:    entry.URL,
+			Weight: entry.Weight,
+		})
+	}
+
+	return endpoints, nil
+}
+
+func (resolver *fileResolver) Watch(ctx context.Context, update func(endpoints []Endpoint)) error {
+	ticker := time.NewTicker(resolver.interval)
+	defer ticker.Stop()
+
+	last, _ := ioutil.ReadFile(resolver.path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		content, err := ioutil.ReadFile(resolver.path)
+
+		if err != nil && !os.IsNotExist(err) {
+			resolver.WarnF("read registry file %s error: %s", resolver.path, err)
+			continue
+		}
+
+		if string(content) == string(last) {
+			continue
+		}
+
+		last = content
+
+		endpoints, err := resolver.Resolve(ctx)
+
+		if err != nil {
+			resolver.WarnF("resolve service %s error: %s", resolver.service, err)
+			continue
+		}
+
+		update(endpoints)
+	}
+}
+
+// DNSResolver resolve service endpoints from DNS SRV records,
+// only the records with the lowest priority are used
+type DNSResolver struct {
+	Service  string        // SRV service name, e.g. http
+	Proto    string        // SRV proto, e.g. tcp
+	Name     string        // domain name
+	Scheme   string        // endpoint url scheme, default http
+	Interval time.Duration // lookup interval, default 30s
+	Resolver *net.Resolver // dns resolver, default net.DefaultResolver
+}
+
+// Resolve .
+func (resolver *DNSResolver) Resolve(ctx context.Context) ([]Endpoint, error) {
+	dns := resolver.Resolver
+
+	if dns == nil {
+		dns = net.DefaultResolver
+	}
+
+	_, records, err := dns.LookupSRV(ctx, resolver.Service, resolver.Proto, resolver.Name)
+
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "lookup srv %s %s %s error", resolver.Service, resolver.Proto, resolver.Name)
+	}
+
+	scheme := resolver.Scheme
+
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	var endpoints []Endpoint
+
+	for _, record := range records {
+		if record.Priority != records[0].Priority {
+			continue
+		}
+
+		endpoints = append(endpoints, Endpoint{
+			URL:    fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), fmt.Sprint(record.Port))),
+			Weight: int(record.Weight),
+		})
+	}
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].URL < endpoints[j].URL
+	})
+
+	return endpoints, nil
+}
+
+// Watch .
+func (resolver *DNSResolver) Watch(ctx context.Context, update func(endpoints []Endpoint)) error {
+	interval := resolver.Interval
+
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := resolver.Resolve(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		endpoints, err := resolver.Resolve(ctx)
+
+		if err != nil {
+			continue
+		}
+
+		if reflect.DeepEqual(endpoints, last) {
+			continue
+		}
+
+		last = endpoints
+
+		update(endpoints)
+	}
+}
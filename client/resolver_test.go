@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/restrpc/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "registry.yaml")
+
+	require.NoError(t, registry.Register(path, "user", registry.Entry{URL: "http://a:80"}))
+
+	resolver := NewFileResolver(path, "user", 10*time.Millisecond)
+
+	endpoints, err := resolver.Resolve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Endpoint{{URL: "http://a:80"}}, endpoints)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan []Endpoint, 1)
+
+	go resolver.Watch(ctx, func(endpoints []Endpoint) {
+		updates <- endpoints
+	})
+
+	time.Sleep(20 * time.Millisecond)
+
+	require.NoError(t, registry.Register(path, "user", registry.Entry{URL: "http://b:80", Weight: 2}))
+
+	select {
+	case endpoints := <-updates:
+		require.Equal(t, []Endpoint{{URL: "http://a:80"}, {URL: "http://b:80", Weight: 2}}, endpoints)
+	case <-time.After(time.Second):
+		t.Fatal("watch update timeout")
+	}
+}
+
+// serveSRV answer every query with the SRV records, targets are host:port strings
+func serveSRV(t *testing.T, targets ...string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		buff := make([]byte, 512)
+
+		for {
+			n, addr, err := conn.ReadFrom(buff)
+
+			if err != nil {
+				return
+			}
+
+			query := buff[:n]
+
+			// skip question name, type and class
+			end := 12
+
+			for query[end] != 0 {
+				end += int(query[end]) + 1
+			}
+
+			end += 5
+
+			resp := append([]byte{}, query[:end]...)
+
+			binary.BigEndian.PutUint16(resp[2:], 0x8180)
+			binary.BigEndian.PutUint16(resp[6:], uint16(len(targets)))
+			binary.BigEndian.PutUint16(resp[8:], 0)
+			binary.BigEndian.PutUint16(resp[10:], 0)
+
+			for _, target := range targets {
+				host, port, _ := net.SplitHostPort(target)
+
+				var name []byte
+
+				for _, label := range strings.Split(host, ".") {
+					name = append(name, byte(len(label)))
+					name = append(name, label...)
+				}
+
+				name = append(name, 0)
+
+				rdata := make([]byte, 6)
+				binary.BigEndian.PutUint16(rdata[0:], 1)
+				binary.BigEndian.PutUint16(rdata[2:], 1)
+				portNumber, _ := net.LookupPort("tcp", port)
+				binary.BigEndian.PutUint16(rdata[4:], uint16(portNumber))
+				rdata = append(rdata, name...)
+
+				record := []byte{0xc0, 0x0c, 0, 33, 0, 1, 0, 0, 0, 60, 0, 0}
+				binary.BigEndian.PutUint16(record[10:], uint16(len(rdata)))
+
+				resp = append(resp, record...)
+				resp = append(resp, rdata...)
+			}
+
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().String()
+}
+
+func TestDNSResolver(t *testing.T) {
+	addr := serveSRV(t, "b.example.test:8081", "a.example.test:8080")
+
+	resolver := &DNSResolver{
+		Service: "http",
+		Proto:   "tcp",
+		Name:    "example.test",
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return net.Dial("udp", addr)
+			},
+		},
+	}
+
+	endpoints, err := resolver.Resolve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Endpoint{
+		{URL: "http://a.example.test:8080", Weight: 1},
+		{URL: "http://b.example.test:8081", Weight: 1},
+	}, endpoints)
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dynamicgo/xerrors/apierr"
+	"github.com/go-resty/resty"
+)
+
+// HeaderIdempotencyKey idempotency key header, POST requests carry it are retryable
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// RetryPolicy client retry policy
+type RetryPolicy struct {
+	MaxAttempts     int           // max attempts include the first one, <= 1 disable retry
+	InitialBackoff  time.Duration // first retry backoff
+	MaxBackoff      time.Duration // backoff upper limit, also limit the Retry-After header value
+	Multiplier      float64       // backoff multiplier
+	Jitter          float64       // random backoff reduction ratio, clamped to 1, negative disables jitter
+	RetryableStatus []int         // retryable http status codes
+	RetryableCodes  []int         // retryable apierr codes
+	Methods         []string      // retryable http methods, POST is always retryable with idempotency key
+}
+
+// DefaultRetryPolicy .
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		Methods: []string{
+			http.MethodGet,
+			http.MethodPut,
+			http.MethodDelete,
+		},
+	}
+}
+
+// WithRetry enable client retry with policy, zero fields are filled with DefaultRetryPolicy
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(client *clientImpl) {
+		defaultPolicy := DefaultRetryPolicy()
+
+		if policy.MaxAttempts == 0 {
+			policy.MaxAttempts = defaultPolicy.MaxAttempts
+		}
+
+		if policy.InitialBackoff == 0 {
+			policy.InitialBackoff = defaultPolicy.InitialBackoff
+		}
+
+		if policy.MaxBackoff == 0 {
+			policy.MaxBackoff = defaultPolicy.MaxBackoff
+		}
+
+		if policy.Multiplier < 1 {
+			policy.Multiplier = defaultPolicy.Multiplier
+		}
+
+		if policy.Jitter == 0 {
+			policy.Jitter = defaultPolicy.Jitter
+		}
+
+		// a ratio above 1 would turn backoff negative
+		if policy.Jitter > 1 {
+			policy.Jitter = 1
+		}
+
+		if policy.RetryableStatus == nil {
+			policy.RetryableStatus = defaultPolicy.RetryableStatus
+		}
+
+		if policy.Methods == nil {
+			policy.Methods = defaultPolicy.Methods
+		}
+
+		client.retry = &policy
+	}
+}
+
+func (policy *RetryPolicy) retryable(method string, request *resty.Request, resp *resty.Response, err error) bool {
+
+	if !policy.retryableMethod(method, request.Header) {
+		return false
+	}
+
+	// network error
+	if resp == nil {
+		return true
+	}
+
+	for _, status := range policy.RetryableStatus {
+		if status == resp.StatusCode() {
+			return true
+		}
+	}
+
+	if len(policy.RetryableCodes) == 0 || resp.StatusCode() == http.StatusOK {
+		return false
+	}
+
+	apiErr := apierr.As(err, nil)
+
+	if apiErr == nil {
+		return false
+	}
+
+	for _, code := range policy.RetryableCodes {
+		if code == apiErr.Code() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (policy *RetryPolicy) retryableMethod(method string, header http.Header) bool {
+
+	if method == http.MethodPost {
+		return header.Get(HeaderIdempotencyKey) != ""
+	}
+
+	for _, retryable := range policy.Methods {
+		if retryable == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (policy *RetryPolicy) backoff(attempt int, resp *resty.Response) time.Duration {
+
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header().Get("Retry-After")); ok {
+			if wait > policy.MaxBackoff {
+				wait = policy.MaxBackoff
+			}
+
+			return wait
+		}
+	}
+
+	backoff := float64(policy.InitialBackoff) * math.Pow(policy.Multiplier, float64(attempt-1))
+
+	if backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+
+	if policy.Jitter > 0 {
+		backoff -= backoff * policy.Jitter * rand.Float64()
+	}
+
+	return time.Duration(backoff)
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+
+		if wait < 0 {
+			wait = 0
+		}
+
+		return wait, true
+	}
+
+	return 0, false
+}
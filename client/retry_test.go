@@ -0,0 +1,88 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newFlakyServer returns a server failing the first failures calls and the call counter
+func newFlakyServer(failures int, status int) (*httptest.Server, func() int) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(atomic.AddInt32(&calls, 1)) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code":-1,"errmsg":"unavailable"}`))
+			return
+		}
+
+		w.Write([]byte(`{"result":{}}`))
+	}))
+
+	return server, func() int { return int(atomic.LoadInt32(&calls)) }
+}
+
+func TestRetryGet(t *testing.T) {
+	server, calls := newFlakyServer(2, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	client := New(server.URL, WithRetry(RetryPolicy{InitialBackoff: time.Millisecond}))
+
+	var reply struct{}
+
+	require.NoError(t, client.Call("/test/hello", http.MethodGet, nil, &reply))
+	require.Equal(t, 3, calls())
+}
+
+func TestRetryPostIdempotency(t *testing.T) {
+	server, calls := newFlakyServer(1, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	client := New(server.URL, WithRetry(RetryPolicy{InitialBackoff: time.Millisecond}))
+
+	var reply struct{}
+
+	require.Error(t, client.Call("/test/hello", http.MethodPost, nil, &reply))
+	require.Equal(t, 1, calls())
+
+	require.NoError(t, client.Call("/test/hello", http.MethodPost, nil, &reply, WithIdempotencyKey("1")))
+	require.Equal(t, 2, calls())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	policy.Jitter = 0
+
+	require.Equal(t, 100*time.Millisecond, policy.backoff(1, nil))
+	require.Equal(t, 400*time.Millisecond, policy.backoff(3, nil))
+	require.Equal(t, policy.MaxBackoff, policy.backoff(20, nil))
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	client := New("http://127.0.0.1", WithRetry(RetryPolicy{MaxAttempts: 2})).(*clientImpl)
+	defer client.Close()
+
+	require.Equal(t, DefaultRetryPolicy().Jitter, client.retry.Jitter)
+
+	client = New("http://127.0.0.1", WithRetry(RetryPolicy{MaxAttempts: 2, Jitter: -1})).(*clientImpl)
+	defer client.Close()
+
+	require.Equal(t, client.retry.InitialBackoff, client.retry.backoff(1, nil))
+}
+
+func TestRetryJitterClamp(t *testing.T) {
+	client := New("http://127.0.0.1", WithRetry(RetryPolicy{MaxAttempts: 2, Jitter: 5})).(*clientImpl)
+	defer client.Close()
+
+	require.Equal(t, 1.0, client.retry.Jitter)
+
+	for i := 0; i < 100; i++ {
+		require.True(t, client.retry.backoff(1, nil) >= 0)
+	}
+}
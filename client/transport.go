@@ -0,0 +1,51 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+)
+
+// WithHTTPClient use a copy of httpClient to send requests, TLS and proxy options only apply
+// to a *http.Transport or the default transport, other round trippers are used as they are
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(client *clientImpl) {
+		client.httpClient = httpClient
+	}
+}
+
+// WithTransport set the http round tripper, TLS and proxy options are ignored
+// if the transport is set
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(client *clientImpl) {
+		client.transport = transport
+	}
+}
+
+// WithTLSConfig set TLS config, e.g. root CAs and client certificates
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(client *clientImpl) {
+		client.tlsConfig = config
+	}
+}
+
+// WithProxy set http proxy url
+func WithProxy(proxyURL *url.URL) ClientOption {
+	return func(client *clientImpl) {
+		client.proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithHeader add base header sent with every request
+func WithHeader(key string, value string) ClientOption {
+	return func(client *clientImpl) {
+		client.headers.Add(key, value)
+	}
+}
+
+// WithUserAgent set User-Agent header
+func WithUserAgent(userAgent string) ClientOption {
+	return func(client *clientImpl) {
+		client.headers.Set("User-Agent", userAgent)
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestClientTransportIsolation(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":{}}`))
+	}))
+	defer testServer.Close()
+
+	var agents []string
+
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	var reply struct{}
+
+	client := New(testServer.URL, WithTransport(transport), WithUserAgent("test-agent"))
+
+	require.NoError(t, client.Call("/test/hello", http.MethodGet, nil, &reply))
+
+	require.NoError(t, New(testServer.URL).Call("/test/hello", http.MethodGet, nil, &reply))
+
+	require.Equal(t, []string{"test-agent"}, agents)
+}
+
+func TestHTTPClientTransportOptions(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":{}}`))
+	}))
+	defer testServer.Close()
+
+	unreachable, err := url.Parse("http://127.0.0.1:1")
+	require.NoError(t, err)
+
+	calls := 0
+
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	var reply struct{}
+
+	// custom round trippers are kept, the proxy is not applied
+	client := New(testServer.URL, WithHTTPClient(&http.Client{Transport: transport}), WithProxy(unreachable))
+
+	require.NoError(t, client.Call("/test/hello", http.MethodGet, nil, &reply))
+	require.Equal(t, 1, calls)
+
+	// a *http.Transport is cloned with the TLS config and proxy
+	base := &http.Transport{}
+	config := &tls.Config{ServerName: "test"}
+
+	client = New(testServer.URL, WithHTTPClient(&http.Client{Transport: base}), WithTLSConfig(config), WithProxy(unreachable))
+
+	cloned := client.(*clientImpl).rawClient.Transport.(*decompressTransport).next.(*authTransport).next.(*http.Transport)
+	require.NotSame(t, base, cloned)
+	require.Same(t, config, cloned.TLSClientConfig)
+	require.NotNil(t, cloned.Proxy)
+	require.Nil(t, base.Proxy)
+}
@@ -2,23 +2,52 @@ package client
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"reflect"
+	"strconv"
 
 	"github.com/dynamicgo/xerrors"
 
 	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/restrpc/validator"
 )
 
 func writeJSON(args interface{}, writer io.Writer) error {
 
-	paramT := reflect.TypeOf(args)
+	if args == nil {
+		return writeNull(writer)
+	}
+
+	value := reflect.ValueOf(args)
+
+	if literal, ok, err := validator.FormatValue(value, nil); ok {
+		if err != nil {
+			return xerrors.Wrapf(err, "format %s error", value.Type())
+		}
+
+		_, err = writer.Write(literal)
+
+		return err
+	}
+
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return writeNull(writer)
+		}
 
-	if paramT.Kind() == reflect.Ptr {
-		paramT = paramT.Elem()
+		if target, ok := args.(restrpc.Writer); ok {
+			return target.Write(writer)
+		}
+
+		value = value.Elem()
+		args = value.Interface()
 	}
 
+	paramT := value.Type()
+
 	switch paramT.Kind() {
 	case reflect.Int, reflect.Int8,
 		reflect.Int16, reflect.Int32,
@@ -30,13 +59,19 @@ func writeJSON(args interface{}, writer io.Writer) error {
 	case reflect.Struct:
 		return writeStruct(args, writer)
 	case reflect.String:
-		return writeString(args, writer)
+		return writeString(value.String(), writer)
 	case reflect.Slice, reflect.Array:
 		return writeArray(args, writer)
 	case reflect.Map:
 		return writeMap(args, writer)
 	case reflect.Bool:
-		return writeBool(args, writer)
+		return writeBool(value.Bool(), writer)
+	case reflect.Interface:
+		if value.IsNil() {
+			return writeNull(writer)
+		}
+
+		return writeJSON(value.Elem().Interface(), writer)
 	default:
 
 		var target restrpc.Writer
@@ -50,37 +85,161 @@ func writeJSON(args interface{}, writer io.Writer) error {
 	}
 }
 
-func writeBool(args interface{}, writer io.Writer) error {
+func writeNull(writer io.Writer) error {
+	_, err := writer.Write([]byte("null"))
+
+	return err
+}
 
-	b := args.(bool)
+func writeBool(b bool, writer io.Writer) error {
 
 	if b {
 		_, err := writer.Write([]byte("true"))
 
-		return err
-	} else {
-		_, err := writer.Write([]byte("false"))
-
 		return err
 	}
+
+	_, err := writer.Write([]byte("false"))
+
+	return err
 }
 
 func writeNumber(args interface{}, writer io.Writer) error {
 
 	v := fmt.Sprintf("%v", args)
 
+	// avoid exponent format, integer fields can not be decoded from 1e+12
+	switch number := reflect.ValueOf(args); number.Kind() {
+	case reflect.Float32, reflect.Float64:
+		v = strconv.FormatFloat(number.Float(), 'f', -1, number.Type().Bits())
+	}
+
 	_, err := writer.Write([]byte(v))
 
 	return err
 }
 
+// writeField write struct field or array element honoring the field metadata, e.g. time layout
+func writeField(value reflect.Value, metadata *validator.Metadata, writer io.Writer) error {
+	literal, ok, err := validator.FormatValue(value, metadata)
+
+	if err != nil {
+		return xerrors.Wrapf(err, "format %s error", value.Type())
+	}
+
+	if ok {
+		_, err = writer.Write(literal)
+		return err
+	}
+
+	if kind := value.Kind(); kind == reflect.Slice || kind == reflect.Array {
+		var buff bytes.Buffer
+
+		buff.WriteString("[")
+
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				buff.WriteString(",")
+			}
+
+			if err := writeField(value.Index(i), metadata, &buff); err != nil {
+				return err
+			}
+		}
+
+		buff.WriteString("]")
+
+		_, err = writer.Write(buff.Bytes())
+
+		return err
+	}
+
+	return writeJSON(value.Interface(), writer)
+}
+
 func writeStruct(args interface{}, writer io.Writer) error {
-	return nil
+
+	var buff bytes.Buffer
+
+	buff.WriteString("{")
+
+	value := reflect.ValueOf(args)
+
+	fields, err := validator.Fields(value.Type())
+
+	if err != nil {
+		return err
+	}
+
+	var presenceSet validator.PresenceSet
+
+	if index, ok := validator.PresenceIndex(value.Type()); ok {
+		presenceSet = value.FieldByIndex(index).Interface().(validator.PresenceSet)
+	}
+
+	first := true
+
+	for _, field := range fields {
+
+		name, metadata := field.Name, field.Metadata
+
+		fieldValue, ok := validator.FieldValue(value, field.Index)
+
+		state := presenceSet.Presence(name)
+
+		if state == validator.Missing {
+			if !ok || (fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil()) {
+				continue
+			}
+
+			// the server applies the default of missing parameters
+			if field.IsDefault(fieldValue) {
+				continue
+			}
+		}
+
+		if !first {
+			buff.WriteString(",")
+		}
+
+		first = false
+
+		if err := writeString(name, &buff); err != nil {
+			return err
+		}
+
+		buff.WriteString(":")
+
+		// fields marked present are sent even if zero or default, null ones as json null
+		if state == validator.Null || !ok {
+			if err := writeNull(&buff); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := writeField(fieldValue, metadata, &buff); err != nil {
+			return err
+		}
+	}
+
+	buff.WriteString("}")
+
+	_, err = writer.Write(buff.Bytes())
+
+	return err
 }
 
-func writeString(args interface{}, writer io.Writer) error {
+func writeString(s string, writer io.Writer) error {
+
+	buff, err := json.Marshal(s)
+
+	if err != nil {
+		return err
+	}
 
-	_, err := writer.Write([]byte(fmt.Sprintf(`"%s"`, args.(string))))
+	_, err = writer.Write(buff)
 
 	return err
 }
@@ -118,20 +277,22 @@ func writeMap(args interface{}, writer io.Writer) error {
 
 	value := reflect.ValueOf(args)
 
+	if value.Type().Key().Kind() != reflect.String {
+		return xerrors.Wrapf(restrpc.ErrMapKey, "only support string key,got %s", value.Type().Key())
+	}
+
 	keys := value.MapKeys()
 
 	for i, key := range keys {
 
-		writeJSON(key.Interface(), &buff)
+		if err := writeString(key.String(), &buff); err != nil {
+			return err
+		}
 
 		buff.WriteString(":")
 
 		keyValue := value.MapIndex(key)
 
-		if keyValue.Type().Kind() != reflect.String {
-			return xerrors.Wrapf(restrpc.ErrMapKey, "only support string key,got %s", keyValue.Type())
-		}
-
 		if err := writeJSON(keyValue.Interface(), &buff); err != nil {
 			return err
 		}
@@ -147,3 +308,120 @@ func writeMap(args interface{}, writer io.Writer) error {
 
 	return err
 }
+
+// writeQuery flatten args into query values, nested keys are joined with '.'
+func writeQuery(args interface{}, prefix string, values url.Values) error {
+	if args == nil {
+		return nil
+	}
+
+	return writeQueryValue(reflect.ValueOf(args), nil, prefix, values)
+}
+
+func writeQueryValue(value reflect.Value, metadata *validator.Metadata, prefix string, values url.Values) error {
+
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+
+		value = value.Elem()
+	}
+
+	text, ok, err := validator.FormatText(value, metadata)
+
+	if err != nil {
+		return xerrors.Wrapf(err, "format %s error", value.Type())
+	}
+
+	if ok {
+		values.Add(prefix, text)
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		fields, err := validator.Fields(value.Type())
+
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+
+			fieldValue, ok := validator.FieldValue(value, field.Index)
+
+			if !ok || field.IsDefault(fieldValue) {
+				continue
+			}
+
+			if err := writeQueryValue(fieldValue, field.Metadata, joinQueryKey(prefix, field.Name), values); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return xerrors.Wrapf(restrpc.ErrMapKey, "only support string key,got %s", value.Type().Key())
+		}
+
+		for _, key := range value.MapKeys() {
+			if err := writeQueryValue(value.MapIndex(key), nil, joinQueryKey(prefix, key.String()), values); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		// scalars are repeated keys (ids=1&ids=2), composite elements are indexed (items.0.name=x)
+		indexed := isComposite(value.Type().Elem())
+
+		for i := 0; i < value.Len(); i++ {
+			key := prefix
+
+			if indexed {
+				key = joinQueryKey(prefix, strconv.Itoa(i))
+			}
+
+			if err := writeQueryValue(value.Index(i), metadata, key, values); err != nil {
+				return err
+			}
+		}
+	case reflect.Int, reflect.Int8,
+		reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint,
+		reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64,
+		reflect.Bool:
+		values.Add(prefix, fmt.Sprintf("%v", value.Interface()))
+	case reflect.String:
+		values.Add(prefix, value.String())
+	default:
+		return xerrors.Wrapf(restrpc.ErrInvalidType, "invalid query param type %s", value.Type())
+	}
+
+	return nil
+}
+
+func isComposite(valueT reflect.Type) bool {
+	for valueT.Kind() == reflect.Ptr {
+		valueT = valueT.Elem()
+	}
+
+	if validator.IsText(valueT) {
+		return false
+	}
+
+	switch valueT.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		return true
+	default:
+		return false
+	}
+}
+
+func joinQueryKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
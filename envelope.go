@@ -0,0 +1,195 @@
+package restrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/dynamicgo/xerrors"
+	"github.com/dynamicgo/xerrors/apierr"
+)
+
+// HeaderEnvelope request header asking for a response envelope by name, the server echoes
+// the envelope it used in the response header so that the client decodes it accordingly
+const HeaderEnvelope = "X-Restrpc-Envelope"
+
+// Built-in envelope names
+const (
+	EnvelopeClassic   = "classic"    // {"result":...} or {"code":...,"errmsg":...}
+	EnvelopeRaw       = "raw"        // the bare result, errors are {"code":...,"errmsg":...} with a non 2xx status
+	EnvelopeDataError = "data-error" // {"data":...} or {"error":{"code":...,"message":...}}
+)
+
+// Envelope response envelope strategy shared by server and client
+type Envelope interface {
+	// Name envelope name negotiated by HeaderEnvelope
+	Name() string
+	// Success returns the json response of result
+	Success(result interface{}) interface{}
+	// Failure returns the json response of api error
+	Failure(err apierr.APIErr) interface{}
+	// Decode returns the raw json result of response body, or the api error it carries,
+	// an explicit null result is returned as nil and a missing result is restrpc.ErrInternal
+	Decode(status int, body []byte) (json.RawMessage, error)
+}
+
+var envelopes sync.Map // name -> Envelope
+
+// RegisterEnvelope register envelope by its name, replacing the registered one
+func RegisterEnvelope(envelope Envelope) {
+	envelopes.Store(envelope.Name(), envelope)
+}
+
+// LookupEnvelope returns the envelope registered with name
+func LookupEnvelope(name string) (Envelope, bool) {
+	envelope, ok := envelopes.Load(name)
+
+	if !ok {
+		return nil, false
+	}
+
+	return envelope.(Envelope), true
+}
+
+func init() {
+	RegisterEnvelope(ClassicEnvelope{})
+	RegisterEnvelope(RawEnvelope{})
+	RegisterEnvelope(DataErrorEnvelope{})
+}
+
+// decodeResult returns nil for an explicit null result, a missing member means the body
+// does not match the envelope, e.g. a raw body decoded as classic
+func decodeResult(raw json.RawMessage, body []byte) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, xerrors.Wrapf(ErrInternal, "result not found: %s", body)
+	}
+
+	if string(raw) == "null" {
+		return nil, nil
+	}
+
+	return raw, nil
+}
+
+func isSuccess(status int) bool {
+	return status >= 200 && status < 300
+}
+
+type classicResponse struct {
+	Code   int             `json:"code,omitempty"`
+	ErrMsg string          `json:"errmsg,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// ClassicEnvelope the default restrpc envelope
+type ClassicEnvelope struct{}
+
+// Name .
+func (ClassicEnvelope) Name() string {
+	return EnvelopeClassic
+}
+
+// Success .
+func (ClassicEnvelope) Success(result interface{}) interface{} {
+	return map[string]interface{}{"result": result}
+}
+
+// Failure .
+func (ClassicEnvelope) Failure(err apierr.APIErr) interface{} {
+	return map[string]interface{}{"code": err.Code(), "errmsg": err.Error()}
+}
+
+// Decode .
+func (ClassicEnvelope) Decode(status int, body []byte) (json.RawMessage, error) {
+	var response classicResponse
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, xerrors.Wrapf(ErrInternal, "unmarshal %s err %s", body, err)
+	}
+
+	if !isSuccess(status) {
+		return nil, apierr.New(response.Code, response.ErrMsg)
+	}
+
+	return decodeResult(response.Result, body)
+}
+
+// RawEnvelope writes the bare result, for partners expecting plain bodies
+type RawEnvelope struct{}
+
+// Name .
+func (RawEnvelope) Name() string {
+	return EnvelopeRaw
+}
+
+// Success .
+func (RawEnvelope) Success(result interface{}) interface{} {
+	return result
+}
+
+// Failure .
+func (RawEnvelope) Failure(err apierr.APIErr) interface{} {
+	return ClassicEnvelope{}.Failure(err)
+}
+
+// Decode .
+func (RawEnvelope) Decode(status int, body []byte) (json.RawMessage, error) {
+	if isSuccess(status) {
+		return decodeResult(bytes.TrimSpace(body), body)
+	}
+
+	var response classicResponse
+
+	if err := json.Unmarshal(body, &response); err != nil || response.Code == 0 {
+		return nil, xerrors.Wrapf(ErrInternal, "unexpected status %d: %s", status, body)
+	}
+
+	return nil, apierr.New(response.Code, response.ErrMsg)
+}
+
+type dataErrorResponse struct {
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// DataErrorEnvelope writes {"data":...} or {"error":{"code":...,"message":...}}
+type DataErrorEnvelope struct{}
+
+// Name .
+func (DataErrorEnvelope) Name() string {
+	return EnvelopeDataError
+}
+
+// Success .
+func (DataErrorEnvelope) Success(result interface{}) interface{} {
+	return map[string]interface{}{"data": result}
+}
+
+// Failure .
+func (DataErrorEnvelope) Failure(err apierr.APIErr) interface{} {
+	return map[string]interface{}{
+		"error": map[string]interface{}{"code": err.Code(), "message": err.Error()},
+	}
+}
+
+// Decode .
+func (DataErrorEnvelope) Decode(status int, body []byte) (json.RawMessage, error) {
+	var response dataErrorResponse
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, xerrors.Wrapf(ErrInternal, "unmarshal %s err %s", body, err)
+	}
+
+	if response.Error != nil {
+		return nil, apierr.New(response.Error.Code, response.Error.Message)
+	}
+
+	if !isSuccess(status) {
+		return nil, xerrors.Wrapf(ErrInternal, "unexpected status %d: %s", status, body)
+	}
+
+	return decodeResult(response.Data, body)
+}
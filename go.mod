@@ -0,0 +1,20 @@
+module github.com/dynamicgo/restrpc
+
+go 1.18
+
+require (
+	github.com/Jeffail/gabs v1.4.0
+	github.com/dynamicgo/xerrors v0.0.0-20190219051451-ec7525ce5de1
+	github.com/go-resty/resty v1.8.0
+	github.com/julienschmidt/httprouter v1.3.0
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/crypto v0.21.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
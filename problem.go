@@ -0,0 +1,95 @@
+package restrpc
+
+import (
+	"encoding/json"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/dynamicgo/xerrors"
+)
+
+// ContentTypeProblem RFC 7807 problem details media type
+const ContentTypeProblem = "application/problem+json"
+
+// FieldError error of a single parameter, reported as problem details field errors
+type FieldError struct {
+	Field  string `json:"field"`  // parameter path
+	Detail string `json:"detail"` // error message
+	Err    error  `json:"-"`      // cause, e.g. validator.ErrNumber
+}
+
+func (err *FieldError) Error() string {
+	return err.Detail
+}
+
+// Unwrap returns the cause
+func (err *FieldError) Unwrap() error {
+	return err.Err
+}
+
+// Problem RFC 7807 problem details document, extended with the apierr code and field errors,
+// it implements apierr.APIErr so that services may return it to control the document
+type Problem struct {
+	Type     string        `json:"type,omitempty"` // problem type URI, "about:blank" if empty
+	Title    string        `json:"title,omitempty"`
+	Status   int           `json:"status,omitempty"`
+	Detail   string        `json:"detail,omitempty"`
+	Instance string        `json:"instance,omitempty"`
+	ErrCode  int           `json:"code,omitempty"`   // apierr code
+	Errors   []*FieldError `json:"errors,omitempty"` // parameter errors
+}
+
+func (problem *Problem) Error() string {
+	if problem.Detail != "" {
+		return problem.Detail
+	}
+
+	return problem.Title
+}
+
+// Code returns the apierr code, documents without code extension are internal errors
+func (problem *Problem) Code() int {
+	if problem.ErrCode == 0 {
+		return ErrInternal.Code()
+	}
+
+	return problem.ErrCode
+}
+
+// IsProblem check whether contentType is the problem details media type
+func IsProblem(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && mediaType == ContentTypeProblem
+}
+
+// AcceptsProblem check whether the Accept header lists the problem details media type, q=0 means not acceptable
+func AcceptsProblem(accept string) bool {
+	for _, token := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(token))
+
+		if err != nil || mediaType != ContentTypeProblem {
+			continue
+		}
+
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// ParseProblem parse problem details document
+func ParseProblem(body []byte) (*Problem, error) {
+	problem := &Problem{}
+
+	if err := json.Unmarshal(body, problem); err != nil {
+		return nil, xerrors.Wrapf(ErrInternal, "unmarshal problem %s err %s", body, err)
+	}
+
+	return problem, nil
+}
@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dynamicgo/xerrors"
+	"gopkg.in/yaml.v2"
+)
+
+// Errors
+var (
+	ErrLocked = errors.New("registry file locked")
+)
+
+// Entry service endpoint entry
+type Entry struct {
+	URL    string `json:"url" yaml:"url"`
+	Weight int    `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// Services registry file content, service name -> endpoints
+type Services map[string][]Entry
+
+// lock file stale timeout
+const lockTimeout = 10 * time.Second
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Parse parse registry file content, the format is detected by path extension
+func Parse(path string, content []byte) (Services, error) {
+	services := make(Services)
+
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return services, nil
+	}
+
+	var err error
+
+	if isYAML(path) {
+		err = yaml.Unmarshal(content, &services)
+	} else {
+		err = json.Unmarshal(content, &services)
+	}
+
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "parse registry file %s error", path)
+	}
+
+	return services, nil
+}
+
+// Load load registry file, missing file is treated as empty registry
+func Load(path string) (Services, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return make(Services), nil
+	}
+
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "read registry file %s error", path)
+	}
+
+	return Parse(path, content)
+}
+
+// Register add or update service endpoint entry
+func Register(path string, service string, entry Entry) error {
+	return update(path, func(services Services) {
+		entries := removeEntry(services[service], entry.URL)
+
+		services[service] = append(entries, entry)
+	})
+}
+
+// Deregister remove service endpoint entry
+func Deregister(path string, service string, url string) error {
+	return update(path, func(services Services) {
+		entries := removeEntry(services[service], url)
+
+		if len(entries) == 0 {
+			delete(services, service)
+		} else {
+			services[service] = entries
+		}
+	})
+}
+
+func removeEntry(entries []Entry, url string) []Entry {
+	var result []Entry
+
+	for _, entry := range entries {
+		if entry.URL != url {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
+
+func update(path string, f func(services Services)) error {
+
+	unlock, err := lock(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	services, err := Load(path)
+
+	if err != nil {
+		return err
+	}
+
+	f(services)
+
+	var content []byte
+
+	if isYAML(path) {
+		content, err = yaml.Marshal(services)
+	} else {
+		content, err = json.MarshalIndent(services, "", "  ")
+	}
+
+	if err != nil {
+		return xerrors.Wrapf(err, "marshal registry file %s error", path)
+	}
+
+	tmp := path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return xerrors.Wrapf(err, "write registry file %s error", tmp)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return xerrors.Wrapf(err, "rename registry file %s error", tmp)
+	}
+
+	return nil
+}
+
+// lock cross process lock with lock file, stale lock files are removed after lockTimeout
+func lock(path string) (func(), error) {
+
+	lockPath := path + ".lock"
+
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+
+		if err == nil {
+			file.Close()
+
+			return func() {
+				os.Remove(lockPath)
+			}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, xerrors.Wrapf(err, "create lock file %s error", lockPath)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > lockTimeout {
+			os.Remove(lockPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, xerrors.Wrapf(ErrLocked, "lock registry file %s timeout", path)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
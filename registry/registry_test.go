@@ -0,0 +1,41 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRegisterFile(t *testing.T, name string) {
+	dir, err := ioutil.TempDir("", "registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, name)
+
+	require.NoError(t, Register(path, "user", Entry{URL: "http://a:80"}))
+	require.NoError(t, Register(path, "user", Entry{URL: "http://b:80", Weight: 2}))
+	require.NoError(t, Register(path, "order", Entry{URL: "http://c:80"}))
+
+	services, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, []Entry{{URL: "http://a:80"}, {URL: "http://b:80", Weight: 2}}, services["user"])
+
+	require.NoError(t, Deregister(path, "user", "http://a:80"))
+	require.NoError(t, Deregister(path, "order", "http://c:80"))
+
+	services, err = Load(path)
+	require.NoError(t, err)
+	require.Equal(t, Services{"user": {{URL: "http://b:80", Weight: 2}}}, services)
+}
+
+func TestRegisterJSON(t *testing.T) {
+	testRegisterFile(t, "registry.json")
+}
+
+func TestRegisterYAML(t *testing.T) {
+	testRegisterFile(t, "registry.yaml")
+}
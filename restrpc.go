@@ -15,6 +15,9 @@ var (
 	ErrMapKey      = errors.New("map key must be string")
 )
 
+// HeaderTimeout request header transmit the caller remaining deadline in milliseconds
+const HeaderTimeout = "X-Restrpc-Timeout"
+
 // Reader parameter reader
 type Reader interface {
 	Search(key string) ([]string, error)
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dynamicgo/xerrors/apierr"
+)
+
+// Authorization errors
+var (
+	ErrUnauthenticated = apierr.New(-1031, "UNAUTHENTICATED")
+	ErrForbidden       = apierr.New(-1032, "FORBIDDEN")
+)
+
+// Requirement method access requirement
+type Requirement struct {
+	Roles  []string `json:"roles,omitempty"`  // caller must have one of the roles
+	Scopes []string `json:"scopes,omitempty"` // caller must have all the scopes
+}
+
+// Permissions optional service interface, returns method name -> requirement,
+// methods without requirement are public, Handle panics if a requirement names an unknown method
+type Permissions interface {
+	Permissions() map[string]Requirement
+}
+
+// Grants caller roles and scopes
+type Grants struct {
+	Roles  []string
+	Scopes []string
+}
+
+// GrantsFromContext collect caller grants: principal roles, JWT "roles" claim and "scope"/"scp" claims,
+// ok is false if the caller is not authenticated
+func GrantsFromContext(ctx context.Context) (grants Grants, ok bool) {
+
+	if principal, found := PrincipalFromContext(ctx); found {
+		ok = true
+		grants.Roles = append(grants.Roles, principal.Roles...)
+	}
+
+	if claims, found := ClaimsFromContext(ctx); found {
+		ok = true
+		grants.Roles = append(grants.Roles, claimStrings(claims["roles"])...)
+
+		if scope, isString := claims["scope"].(string); isString {
+			grants.Scopes = append(grants.Scopes, strings.Fields(scope)...)
+		}
+
+		grants.Scopes = append(grants.Scopes, claimStrings(claims["scp"])...)
+	}
+
+	if _, found := SignerFromContext(ctx); found {
+		ok = true
+	}
+
+	return
+}
+
+func claimStrings(value interface{}) []string {
+	var result []string
+
+	switch v := value.(type) {
+	case string:
+		result = append(result, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allow check whether grants satisfy the requirement
+func (requirement *Requirement) Allow(grants Grants) bool {
+
+	if len(requirement.Roles) > 0 {
+		allowed := false
+
+		for _, role := range requirement.Roles {
+			if contains(grants.Roles, role) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return false
+		}
+	}
+
+	for _, scope := range requirement.Scopes {
+		if !contains(grants.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// authorize returns status code and error if the request is not allowed
+func (requirement *Requirement) authorize(r *http.Request) (int, error) {
+	grants, ok := GrantsFromContext(r.Context())
+
+	if !ok {
+		return http.StatusUnauthorized, ErrUnauthenticated
+	}
+
+	if !requirement.Allow(grants) {
+		return http.StatusForbidden, ErrForbidden
+	}
+
+	return http.StatusOK, nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type adminService struct {
+	echoService
+}
+
+func (service *adminService) Permissions() map[string]Requirement {
+	return map[string]Requirement{
+		"PostEcho": {Roles: []string{"admin"}},
+	}
+}
+
+func TestMethodAuthorization(t *testing.T) {
+	store := NewMemoryStore(
+		Credential{Principal: Principal{Name: "alice", Roles: []string{"admin"}}, Keys: []APIKey{{Key: "alice"}}},
+		Credential{Principal: Principal{Name: "bob"}, Keys: []APIKey{{Key: "bob"}}},
+	)
+
+	apiServer := New().Handle("/test", &adminService{}, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		if r.Header.Get("X-API-Key") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		APIKeyMiddleware(store, APIKeyConfig{})(w, r, next)
+	})
+
+	postEcho := func(key string) *httptest.ResponseRecorder {
+		r := postJSON("/test/echo", `{}`)
+
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+
+		return serve(apiServer, r)
+	}
+
+	require.Equal(t, http.StatusOK, serve(apiServer, httptest.NewRequest(http.MethodGet, "/test/echo", nil)).Code)
+	require.Equal(t, http.StatusOK, postEcho("alice").Code)
+
+	requireError(t, postEcho("bob"), http.StatusForbidden, ErrForbidden.Code())
+	requireError(t, postEcho(""), http.StatusUnauthorized, ErrUnauthenticated.Code())
+
+	var requirements []string
+
+	for _, route := range apiServer.Routes() {
+		if route.Requirement != nil {
+			requirements = append(requirements, route.Method+" "+route.Path)
+		}
+	}
+
+	require.Equal(t, []string{"POST /test/echo"}, requirements)
+}
+
+type typoService struct {
+	echoService
+}
+
+func (service *typoService) Permissions() map[string]Requirement {
+	return map[string]Requirement{
+		"PostEcho": {Roles: []string{"admin"}},
+		"GetEcoh":  {Roles: []string{"admin"}},
+	}
+}
+
+func TestUnknownPermissions(t *testing.T) {
+	apiServer := New()
+
+	require.PanicsWithValue(t, "service *server.typoService permissions declare unknown method GetEcoh", func() {
+		apiServer.Handle("/test", &typoService{})
+	})
+
+	require.Empty(t, apiServer.Routes())
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dynamicgo/xerrors"
+)
+
+// defaultMaxDecodedBytes decoded request body limit if CompressionConfig does not set one
+const defaultMaxDecodedBytes = 32 << 20
+
+// CompressionConfig response compression config
+type CompressionConfig struct {
+	MinSize         int   // min response size to compress, default 1024
+	Level           int   // compression level, default flate.DefaultCompression
+	MaxDecodedBytes int64 // max decoded request body bytes, default 32MB, the server Limits.MaxBodyBytes applies too
+}
+
+// WithCompression enable gzip/deflate response compression negotiated by Accept-Encoding and
+// gzip/deflate request bodies, encoded request bodies are refused with 415 if compression is not enabled
+func WithCompression(config CompressionConfig) Option {
+	return func(server *serverImpl) {
+		if config.MinSize <= 0 {
+			config.MinSize = 1024
+		}
+
+		if config.MaxDecodedBytes <= 0 {
+			config.MaxDecodedBytes = defaultMaxDecodedBytes
+		}
+
+		if config.Level == 0 {
+			config.Level = flate.DefaultCompression
+		}
+
+		server.compression = &config
+	}
+}
+
+// negotiateEncoding select the gzip or deflate coding with the highest q value from Accept-Encoding,
+// "*" matches the codings not listed, q=0 means not acceptable and gzip wins ties
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+
+	for _, token := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(strings.TrimSpace(token), ";")
+
+		encoding := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		if encoding == "" {
+			continue
+		}
+
+		quality := 1.0
+
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = value
+				}
+			}
+		}
+
+		qualities[encoding] = quality
+	}
+
+	var selected string
+
+	var selectedQuality float64
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		quality, ok := qualities[encoding]
+
+		if !ok {
+			quality = qualities["*"]
+		}
+
+		if quality > selectedQuality {
+			selected, selectedQuality = encoding, quality
+		}
+	}
+
+	return selected
+}
+
+// compressResponseWriter compress the response if the first write is larger than MinSize
+type compressResponseWriter struct {
+	http.ResponseWriter
+	config   *CompressionConfig
+	encoding string
+	code     int
+	decided  bool
+	writer   io.WriteCloser
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *compressResponseWriter) Write(buff []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+
+		header := w.Header()
+
+		if len(buff) >= w.config.MinSize && header.Get("Content-Encoding") == "" {
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+
+			if w.encoding == "gzip" {
+				w.writer, _ = gzip.NewWriterLevel(w.ResponseWriter, w.config.Level)
+			} else {
+				w.writer, _ = zlib.NewWriterLevel(w.ResponseWriter, w.config.Level)
+			}
+		}
+
+		if w.code != 0 {
+			w.ResponseWriter.WriteHeader(w.code)
+		}
+	}
+
+	if w.writer != nil {
+		return w.writer.Write(buff)
+	}
+
+	return w.ResponseWriter.Write(buff)
+}
+
+func (w *compressResponseWriter) Close() error {
+	if !w.decided && w.code != 0 {
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+
+	if w.writer != nil {
+		return w.writer.Close()
+	}
+
+	return nil
+}
+
+// decodeBody returns request body reader decoded by Content-Encoding, encoded is false for identity bodies.
+// Encoded bodies are refused if compression is not enabled
+func decodeBody(r *http.Request, config *CompressionConfig) (body io.ReadCloser, encoded bool, err error) {
+	encoding := strings.ToLower(r.Header.Get("Content-Encoding"))
+
+	if encoding == "" || encoding == "identity" {
+		return r.Body, false, nil
+	}
+
+	if config == nil {
+		return nil, false, xerrors.Wrapf(ErrUnsupportContentEncoding, "content-encoding %s without server compression", encoding)
+	}
+
+	switch encoding {
+	case "gzip":
+		reader, err := gzip.NewReader(r.Body)
+
+		if err != nil {
+			return nil, false, xerrors.Wrapf(ErrMalformedRequest, "create gzip reader error: %s", err)
+		}
+
+		return reader, true, nil
+	case "deflate":
+		reader, err := zlib.NewReader(r.Body)
+
+		if err != nil {
+			return nil, false, xerrors.Wrapf(ErrMalformedRequest, "create deflate reader error: %s", err)
+		}
+
+		return reader, true, nil
+	default:
+		return nil, false, xerrors.Wrapf(ErrUnsupportContentEncoding, "unsupport content-encoding %s", r.Header.Get("Content-Encoding"))
+	}
+}
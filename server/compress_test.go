@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	handler := New(WithCompression(CompressionConfig{MinSize: 64})).Handle("/test", &echoService{})
+
+	var body bytes.Buffer
+
+	writer := gzip.NewWriter(&body)
+	writer.Write([]byte(`{"name":"` + strings.Repeat("a", 128) + `","n":3}`))
+	writer.Close()
+
+	r := postJSON("/test/echo", body.String())
+	r.Header.Set("Content-Encoding", "gzip")
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	w := serve(handler, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	reader, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+
+	response, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"result":{"name":"`+strings.Repeat("a", 128)+`","count":3}}`, string(response))
+
+	// small replies are sent as is
+	r = postJSON("/test/echo", `{"n":4}`)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	w = serve(handler, r)
+
+	require.Equal(t, "", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.JSONEq(t, `{"result":{"name":"","count":4}}`, w.Body.String())
+}
+
+func TestCompressionRequestErrors(t *testing.T) {
+	var bomb bytes.Buffer
+
+	writer := gzip.NewWriter(&bomb)
+	writer.Write([]byte(`{"name":"` + strings.Repeat("a", 4096) + `"}`))
+	writer.Close()
+
+	post := func(handler http.Handler, encoding string, body string) *httptest.ResponseRecorder {
+		r := postJSON("/test/echo", body)
+		r.Header.Set("Content-Encoding", encoding)
+		return serve(handler, r)
+	}
+
+	// encoded bodies are not decoded without compression
+	handler := New().Handle("/test", &echoService{})
+
+	w := post(handler, "gzip", bomb.String())
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code, w.Body.String())
+	require.Equal(t, "", w.Header().Get("Vary"))
+
+	handler = New(WithCompression(CompressionConfig{MaxDecodedBytes: 1024})).Handle("/test", &echoService{})
+
+	requireError(t, post(handler, "gzip", bomb.String()), http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Code())
+	requireError(t, post(handler, "gzip", `{"n":1}`), http.StatusBadRequest, ErrMalformedRequest.Code())
+	requireError(t, post(handler, "gzip", bomb.String()[:bomb.Len()/2]), http.StatusBadRequest, ErrMalformedRequest.Code())
+	requireError(t, post(handler, "deflate", `{"n":1}`), http.StatusBadRequest, ErrMalformedRequest.Code())
+
+	w = post(handler, "br", `{"n":1}`)
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code, w.Body.String())
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	handler := New(WithCompression(CompressionConfig{MinSize: 16})).Handle("/test", &echoService{})
+
+	for acceptEncoding, expected := range map[string]string{
+		"":                          "",
+		"gzip":                      "gzip",
+		"deflate":                   "deflate",
+		"gzip, deflate":             "gzip",
+		"identity":                  "",
+		"*":                         "gzip",
+		"gzip;q=0, *":               "deflate",
+		"gzip;q=0, deflate;q=0, *":  "",
+		"deflate;q=1, gzip;q=0.1":   "deflate",
+		"gzip;q=0.5, deflate;q=0.8": "deflate",
+		"gzip;q=0.8, deflate;q=0.8": "gzip",
+		"*;q=0":                     "",
+		"br, deflate;q=0.2":         "deflate",
+		"GZIP;q=0.3, *;q=0.1":       "gzip",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/test/echo?name="+strings.Repeat("a", 64), nil)
+
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+
+		require.Equal(t, expected, serve(handler, r).Header().Get("Content-Encoding"), acceptEncoding)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig CORS config
+type CORSConfig struct {
+	AllowedOrigins   []string      // allowed origins, "*" allow all, patterns like "https://*.example.com" supported
+	AllowedMethods   []string      // allowed methods, default the methods registered for the requested path
+	AllowedHeaders   []string      // allowed request headers, default echo Access-Control-Request-Headers
+	ExposedHeaders   []string      // response headers exposed to browsers
+	AllowCredentials bool          // allow cookies and authorization headers, not allowed with the "*" origin
+	MaxAge           time.Duration // preflight cache duration
+}
+
+// WithCORS enable CORS, preflight requests are answered for every registered route.
+// WithCORS panics if the "*" origin is combined with AllowCredentials, any site could then
+// send credentialed requests and read the responses, list the trusted origins instead
+func WithCORS(config CORSConfig) Option {
+	if config.AllowCredentials && contains(config.AllowedOrigins, "*") {
+		panic("CORS AllowCredentials can not be combined with the \"*\" origin")
+	}
+
+	return func(server *serverImpl) {
+		server.cors = &config
+	}
+}
+
+func (config *CORSConfig) allowOrigin(origin string) bool {
+	for _, allowed := range config.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		if strings.Contains(allowed, "*") {
+			if matched, err := path.Match(allowed, origin); err == nil && matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// handleCORS decorate response with CORS headers, returns true if the request is a handled preflight
+func (server *serverImpl) handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	config := server.cors
+
+	origin := r.Header.Get("Origin")
+
+	if origin == "" {
+		return false
+	}
+
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	header := w.Header()
+
+	header.Add("Vary", "Origin")
+
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	methods := server.routeMethods(r.URL.Path)
+
+	if !config.allowOrigin(origin) || len(methods) == 0 {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+		}
+
+		return preflight
+	}
+
+	if contains(config.AllowedOrigins, "*") {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if config.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if len(config.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
+		}
+
+		return false
+	}
+
+	if len(config.AllowedMethods) > 0 {
+		methods = config.AllowedMethods
+	}
+
+	if !contains(methods, strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))) {
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	}
+
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+	if len(config.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(config.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+
+	if config.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge/time.Second)))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+// routeMethods returns the http methods registered for path
+func (server *serverImpl) routeMethods(requestPath string) []string {
+	var methods []string
+
+	for _, route := range server.routes {
+		if path.Clean(route.Path) == path.Clean(requestPath) && !contains(methods, route.Method) {
+			methods = append(methods, route.Method)
+		}
+	}
+
+	return methods
+}
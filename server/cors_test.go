@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	handler := New(WithCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	})).Handle("/test", &echoService{})
+
+	preflight := func(origin string, method string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "/test/echo", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", method)
+		r.Header.Set("Access-Control-Request-Headers", "Content-Type")
+
+		return serve(handler, r)
+	}
+
+	w := preflight("https://app.example.com", http.MethodPost)
+
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "60", w.Header().Get("Access-Control-Max-Age"))
+	require.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+
+	require.Equal(t, http.StatusForbidden, preflight("https://evil.com", http.MethodPost).Code)
+	require.Equal(t, http.StatusForbidden, preflight("https://app.example.com", http.MethodPut).Code)
+
+	r := httptest.NewRequest(http.MethodGet, "/test/echo", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+
+	w = serve(handler, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSWildcard(t *testing.T) {
+	require.Panics(t, func() {
+		WithCORS(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	})
+
+	handler := New(WithCORS(CORSConfig{AllowedOrigins: []string{"*"}})).Handle("/test", &echoService{})
+
+	r := httptest.NewRequest(http.MethodGet, "/test/echo", nil)
+	r.Header.Set("Origin", "https://any.example.org")
+
+	w := serve(handler, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "", w.Header().Get("Access-Control-Allow-Credentials"))
+}
@@ -0,0 +1,353 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dynamicgo/slf4go"
+	"github.com/dynamicgo/xerrors"
+	"github.com/dynamicgo/xerrors/apierr"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// Credential errors
+var (
+	ErrCredentialsMissing = apierr.New(-1021, "CREDENTIALS_MISSING")
+	ErrCredentialsInvalid = apierr.New(-1022, "CREDENTIALS_INVALID")
+)
+
+// Principal authenticated caller
+type Principal struct {
+	Name  string   `json:"name" yaml:"name"`
+	Roles []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext get principal authenticated by APIKeyMiddleware or BasicAuthMiddleware
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// WithPrincipal returns context carrying principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// APIKey api key, multiple active keys of one principal allow rotation
+type APIKey struct {
+	Key     string    `json:"key" yaml:"key"`                             // plain key or "sha256:<hex>"
+	Expires time.Time `json:"expires,omitempty" yaml:"expires,omitempty"` // zero never expires
+}
+
+// Credential principal credentials
+type Credential struct {
+	Principal `yaml:",inline"`
+	Keys      []APIKey `json:"keys,omitempty" yaml:"keys,omitempty"`
+	Password  string   `json:"password,omitempty" yaml:"password,omitempty"` // bcrypt hash "$2a$..." or plain password
+}
+
+// CredentialStore credential store
+type CredentialStore interface {
+	LookupAPIKey(key string) (*Principal, bool)
+	LookupBasic(username string, password string) (*Principal, bool)
+}
+
+// matchKey compare api key with the stored plain or "sha256:<hex>" key, a fast hash only suits random keys
+func matchKey(stored string, key string) bool {
+	if strings.HasPrefix(stored, "sha256:") {
+		hash := sha256.Sum256([]byte(key))
+		key = "sha256:" + hex.EncodeToString(hash[:])
+	}
+
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(key)) == 1
+}
+
+// isBcrypt check whether the stored password is a bcrypt hash
+func isBcrypt(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// matchPassword compare password with the stored bcrypt hash or plain password,
+// other hashes, e.g. the "sha256:" form of api keys, never match
+func matchPassword(stored string, password string) bool {
+	if isBcrypt(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+
+	if stored == "" || strings.HasPrefix(stored, "sha256:") {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+var (
+	dummyOnce sync.Once
+	dummyHash string
+)
+
+// dummyPassword returns a bcrypt hash compared for unknown users, so that the response time
+// does not reveal whether a user exists
+func dummyPassword() string {
+	dummyOnce.Do(func() {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("restrpc dummy password"), bcrypt.DefaultCost)
+		dummyHash = string(hash)
+	})
+
+	return dummyHash
+}
+
+func lookupAPIKey(credentials []Credential, key string) (*Principal, bool) {
+	now := time.Now()
+
+	for i := range credentials {
+		for _, apiKey := range credentials[i].Keys {
+			if !apiKey.Expires.IsZero() && apiKey.Expires.Before(now) {
+				continue
+			}
+
+			if matchKey(apiKey.Key, key) {
+				principal := credentials[i].Principal
+				return &principal, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func lookupBasic(credentials []Credential, username string, password string) (*Principal, bool) {
+	for i := range credentials {
+		if credentials[i].Name != username || credentials[i].Password == "" {
+			continue
+		}
+
+		if !matchPassword(credentials[i].Password, password) {
+			return nil, false
+		}
+
+		principal := credentials[i].Principal
+		return &principal, true
+	}
+
+	matchPassword(dummyPassword(), password)
+
+	return nil, false
+}
+
+// MemoryStore in memory credential store
+type MemoryStore struct {
+	sync.RWMutex
+	credentials []Credential
+}
+
+// NewMemoryStore .
+func NewMemoryStore(credentials ...Credential) *MemoryStore {
+	return &MemoryStore{
+		credentials: credentials,
+	}
+}
+
+// Set add or replace principal credential
+func (store *MemoryStore) Set(credential Credential) {
+	store.Lock()
+	defer store.Unlock()
+
+	for i := range store.credentials {
+		if store.credentials[i].Name == credential.Name {
+			store.credentials[i] = credential
+			return
+		}
+	}
+
+	store.credentials = append(store.credentials, credential)
+}
+
+// Remove remove principal credential
+func (store *MemoryStore) Remove(name string) {
+	store.Lock()
+	defer store.Unlock()
+
+	for i := range store.credentials {
+		if store.credentials[i].Name == name {
+			store.credentials = append(store.credentials[:i], store.credentials[i+1:]...)
+			return
+		}
+	}
+}
+
+// LookupAPIKey .
+func (store *MemoryStore) LookupAPIKey(key string) (*Principal, bool) {
+	store.RLock()
+	defer store.RUnlock()
+
+	return lookupAPIKey(store.credentials, key)
+}
+
+// LookupBasic .
+func (store *MemoryStore) LookupBasic(username string, password string) (*Principal, bool) {
+	store.RLock()
+	defer store.RUnlock()
+
+	return lookupBasic(store.credentials, username, password)
+}
+
+type fileStore struct {
+	sync.Mutex
+	slf4go.Logger
+	path        string
+	refresh     time.Duration
+	credentials []Credential
+	modTime     time.Time
+	checkTime   time.Time
+}
+
+// NewFileStore create credential store backed by JSON/YAML file with a list of Credential,
+// the file is reloaded when modified, checked at most once per refresh, refresh <= 0 disables reload
+func NewFileStore(path string, refresh time.Duration) (CredentialStore, error) {
+	store := &fileStore{
+		Logger:  slf4go.Get("server"),
+		path:    path,
+		refresh: refresh,
+	}
+
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (store *fileStore) reload() error {
+	store.checkTime = time.Now()
+
+	info, err := os.Stat(store.path)
+
+	if err != nil {
+		return xerrors.Wrapf(err, "stat credential file %s error", store.path)
+	}
+
+	if info.ModTime().Equal(store.modTime) {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(store.path)
+
+	if err != nil {
+		return xerrors.Wrapf(err, "read credential file %s error", store.path)
+	}
+
+	var credentials []Credential
+
+	ext := strings.ToLower(filepath.Ext(store.path))
+
+	if ext == ".yaml" || ext == ".yml" {
+		err = yaml.Unmarshal(content, &credentials)
+	} else {
+		err = json.Unmarshal(content, &credentials)
+	}
+
+	if err != nil {
+		return xerrors.Wrapf(err, "parse credential file %s error", store.path)
+	}
+
+	store.credentials = credentials
+	store.modTime = info.ModTime()
+
+	return nil
+}
+
+func (store *fileStore) current() []Credential {
+	store.Lock()
+	defer store.Unlock()
+
+	if store.refresh > 0 && time.Since(store.checkTime) > store.refresh {
+		// keep the loaded credentials until the file is fixed
+		if err := store.reload(); err != nil {
+			store.ErrorF("reload credential file %s error: %s", store.path, err)
+		}
+	}
+
+	return store.credentials
+}
+
+func (store *fileStore) LookupAPIKey(key string) (*Principal, bool) {
+	return lookupAPIKey(store.current(), key)
+}
+
+func (store *fileStore) LookupBasic(username string, password string) (*Principal, bool) {
+	return lookupBasic(store.current(), username, password)
+}
+
+// APIKeyConfig api key middleware config
+type APIKeyConfig struct {
+	Header string // api key header, default X-API-Key
+	Query  string // api key query parameter, empty disable
+}
+
+// APIKeyMiddleware authenticate api key in header or query
+func APIKeyMiddleware(store CredentialStore, config APIKeyConfig) Middleware {
+
+	if config.Header == "" {
+		config.Header = "X-API-Key"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		key := r.Header.Get(config.Header)
+
+		if key == "" && config.Query != "" {
+			key = r.URL.Query().Get(config.Query)
+		}
+
+		if key == "" {
+			WriteError(w, r, http.StatusUnauthorized, ErrCredentialsMissing)
+			return
+		}
+
+		principal, ok := store.LookupAPIKey(key)
+
+		if !ok {
+			WriteError(w, r, http.StatusUnauthorized, ErrCredentialsInvalid)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// BasicAuthMiddleware authenticate HTTP Basic credentials, store passwords as bcrypt hashes,
+// plain passwords are compared in constant time but are meant for tests
+func BasicAuthMiddleware(store CredentialStore, realm string) Middleware {
+	return func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		username, password, ok := r.BasicAuth()
+
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			WriteError(w, r, http.StatusUnauthorized, ErrCredentialsMissing)
+			return
+		}
+
+		principal, ok := store.LookupBasic(username, password)
+
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			WriteError(w, r, http.StatusUnauthorized, ErrCredentialsInvalid)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type principalService struct {
+}
+
+type principalResult struct {
+	Name string `json:"name"`
+}
+
+func (service *principalService) GetPrincipal(ctx context.Context, p *echoParam, r *principalResult) error {
+	principal, _ := PrincipalFromContext(ctx)
+	r.Name = principal.Name
+	return nil
+}
+
+func getPrincipal(handler http.Handler, setup func(r *http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/test/principal?api_key=query", nil)
+	setup(r)
+	return serve(handler, r)
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	next := sha256.Sum256([]byte("next"))
+
+	store := NewMemoryStore(Credential{
+		Principal: Principal{Name: "alice"},
+		Keys: []APIKey{
+			{Key: "old", Expires: time.Now().Add(-time.Minute)},
+			{Key: "current"},
+			{Key: "sha256:" + hex.EncodeToString(next[:])},
+		},
+	}, Credential{
+		Principal: Principal{Name: "bob"},
+		Keys:      []APIKey{{Key: "query"}},
+	})
+
+	handler := New().Handle("/test", &principalService{}, APIKeyMiddleware(store, APIKeyConfig{Query: "api_key"}))
+
+	for key, name := range map[string]string{"current": "alice", "next": "alice"} {
+		w := getPrincipal(handler, func(r *http.Request) { r.Header.Set("X-API-Key", key) })
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.JSONEq(t, `{"result":{"name":"`+name+`"}}`, w.Body.String())
+	}
+
+	// the header wins over the query parameter
+	w := getPrincipal(handler, func(r *http.Request) {})
+	require.JSONEq(t, `{"result":{"name":"bob"}}`, w.Body.String())
+
+	requireError(t, getPrincipal(handler, func(r *http.Request) { r.Header.Set("X-API-Key", "old") }), http.StatusUnauthorized, ErrCredentialsInvalid.Code())
+
+	// the hash itself is not a key
+	requireError(t, getPrincipal(handler, func(r *http.Request) { r.Header.Set("X-API-Key", "sha256:"+hex.EncodeToString(next[:])) }), http.StatusUnauthorized, ErrCredentialsInvalid.Code())
+
+	handler = New().Handle("/test", &principalService{}, APIKeyMiddleware(store, APIKeyConfig{}))
+
+	requireError(t, getPrincipal(handler, func(r *http.Request) {}), http.StatusUnauthorized, ErrCredentialsMissing.Code())
+}
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	weak := sha256.Sum256([]byte("secret"))
+
+	store := NewMemoryStore(
+		Credential{Principal: Principal{Name: "alice"}, Password: string(hash)},
+		Credential{Principal: Principal{Name: "bob"}, Password: "plain"},
+		Credential{Principal: Principal{Name: "carol"}, Password: "sha256:" + hex.EncodeToString(weak[:])},
+		Credential{Principal: Principal{Name: "dave"}},
+	)
+
+	handler := New().Handle("/test", &principalService{}, BasicAuthMiddleware(store, "test"))
+
+	basic := func(username string, password string) *httptest.ResponseRecorder {
+		return getPrincipal(handler, func(r *http.Request) { r.SetBasicAuth(username, password) })
+	}
+
+	w := basic("alice", "secret")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.JSONEq(t, `{"result":{"name":"alice"}}`, w.Body.String())
+
+	w = basic("bob", "plain")
+	require.JSONEq(t, `{"result":{"name":"bob"}}`, w.Body.String())
+
+	for _, credentials := range [][2]string{
+		{"alice", "wrong"},
+		{"alice", string(hash)},
+		{"carol", "secret"},
+		{"carol", "sha256:" + hex.EncodeToString(weak[:])},
+		{"dave", ""},
+		{"unknown", "secret"},
+	} {
+		w = basic(credentials[0], credentials[1])
+		requireError(t, w, http.StatusUnauthorized, ErrCredentialsInvalid.Code())
+		require.Equal(t, `Basic realm="test"`, w.Header().Get("WWW-Authenticate"))
+	}
+
+	w = getPrincipal(handler, func(r *http.Request) {})
+	requireError(t, w, http.StatusUnauthorized, ErrCredentialsMissing.Code())
+	require.Equal(t, `Basic realm="test"`, w.Header().Get("WWW-Authenticate"))
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credential")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials.yaml")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("- name: bob\n  roles: [admin]\n  keys:\n  - key: k1\n"), 0644))
+
+	store, err := NewFileStore(path, time.Millisecond)
+	require.NoError(t, err)
+
+	static, err := NewFileStore(path, 0)
+	require.NoError(t, err)
+
+	principal, ok := store.LookupAPIKey("k1")
+	require.True(t, ok)
+	require.Equal(t, &Principal{Name: "bob", Roles: []string{"admin"}}, principal)
+
+	// the modified file is reloaded after refresh
+	require.NoError(t, ioutil.WriteFile(path, []byte("- name: bob\n  keys:\n  - key: k2\n"), 0644))
+	require.NoError(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+	time.Sleep(2 * time.Millisecond)
+
+	_, ok = store.LookupAPIKey("k1")
+	require.False(t, ok)
+
+	_, ok = store.LookupAPIKey("k2")
+	require.True(t, ok)
+
+	// a broken file keeps the loaded credentials
+	require.NoError(t, ioutil.WriteFile(path, []byte("- name: [bob\n"), 0644))
+	require.NoError(t, os.Chtimes(path, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute)))
+
+	time.Sleep(2 * time.Millisecond)
+
+	_, ok = store.LookupAPIKey("k2")
+	require.True(t, ok)
+
+	// refresh <= 0 never reloads
+	_, ok = static.LookupAPIKey("k1")
+	require.True(t, ok)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/dynamicgo/restrpc"
+)
+
+// WithEnvelope set the default response envelope, e.g. restrpc.RawEnvelope{},
+// clients may ask for another registered envelope with restrpc.HeaderEnvelope
+func WithEnvelope(envelope restrpc.Envelope) Option {
+	return func(server *serverImpl) {
+		server.envelope = envelope
+	}
+}
+
+// negotiateEnvelope select the envelope requested by restrpc.HeaderEnvelope, unknown names fall back to the default
+func (server *serverImpl) negotiateEnvelope(r *http.Request) restrpc.Envelope {
+	if name := r.Header.Get(restrpc.HeaderEnvelope); name != "" {
+		if envelope, ok := server.lookupEnvelope(name); ok {
+			return envelope
+		}
+	}
+
+	return server.envelope
+}
+
+func (server *serverImpl) lookupEnvelope(name string) (restrpc.Envelope, bool) {
+	if name == server.envelope.Name() {
+		return server.envelope, true
+	}
+
+	return restrpc.LookupEnvelope(name)
+}
+
+// responseEnvelope returns the envelope negotiated by ServeHTTP, Success and Fail may be called outside of it
+func (server *serverImpl) responseEnvelope(w http.ResponseWriter) restrpc.Envelope {
+	if envelope, ok := server.lookupEnvelope(w.Header().Get(restrpc.HeaderEnvelope)); ok {
+		return envelope
+	}
+
+	return server.envelope
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/xerrors/apierr"
+	"github.com/stretchr/testify/require"
+)
+
+var errEnvelope = apierr.New(-7, "boom")
+
+type envelopeService struct {
+	echoService
+}
+
+func (service *envelopeService) GetFail(p *echoParam, r *echoResult) error {
+	return errEnvelope
+}
+
+func getEnvelope(handler http.Handler, url string, envelope string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+
+	if envelope != "" {
+		r.Header.Set(restrpc.HeaderEnvelope, envelope)
+	}
+
+	return serve(handler, r)
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	handler := New(WithEnvelope(restrpc.RawEnvelope{})).Handle("/test", &envelopeService{})
+
+	w := getEnvelope(handler, "/test/echo?name=a&n=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, restrpc.EnvelopeRaw, w.Header().Get(restrpc.HeaderEnvelope))
+	require.JSONEq(t, `{"name":"a","count":1}`, w.Body.String())
+
+	w = getEnvelope(handler, "/test/fail", "")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.JSONEq(t, `{"code":-7,"errmsg":"boom"}`, w.Body.String())
+
+	w = getEnvelope(handler, "/test/echo?name=a&n=1", restrpc.EnvelopeDataError)
+	require.Equal(t, restrpc.EnvelopeDataError, w.Header().Get(restrpc.HeaderEnvelope))
+	require.JSONEq(t, `{"data":{"name":"a","count":1}}`, w.Body.String())
+
+	w = getEnvelope(handler, "/test/fail", restrpc.EnvelopeDataError)
+	require.JSONEq(t, `{"error":{"code":-7,"message":"boom"}}`, w.Body.String())
+
+	// unknown envelopes fall back to the server default
+	w = getEnvelope(handler, "/test/echo?name=a&n=1", "unknown")
+	require.Equal(t, restrpc.EnvelopeRaw, w.Header().Get(restrpc.HeaderEnvelope))
+	require.JSONEq(t, `{"name":"a","count":1}`, w.Body.String())
+
+	w = getEnvelope(New().Handle("/test", &envelopeService{}), "/test/echo?name=a&n=1", "")
+	require.Equal(t, restrpc.EnvelopeClassic, w.Header().Get(restrpc.HeaderEnvelope))
+	require.JSONEq(t, `{"result":{"name":"a","count":1}}`, w.Body.String())
+}
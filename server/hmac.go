@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/xerrors"
+	"github.com/dynamicgo/xerrors/apierr"
+)
+
+// HMAC signature errors
+var (
+	ErrSignatureMissing = apierr.New(-1011, "SIGNATURE_MISSING")
+	ErrSignatureKey     = apierr.New(-1012, "SIGNATURE_KEY_UNKNOWN")
+	ErrSignatureInvalid = apierr.New(-1013, "SIGNATURE_INVALID")
+	ErrSignatureSkew    = apierr.New(-1014, "SIGNATURE_TIMESTAMP_SKEW")
+	ErrSignatureReplay  = apierr.New(-1015, "SIGNATURE_REPLAY")
+)
+
+// NonceCache replay protection nonce cache
+type NonceCache interface {
+	// Add returns false if the nonce is already seen and not expired
+	Add(nonce string, expire time.Time) bool
+}
+
+type memoryNonceCache struct {
+	sync.Mutex
+	nonces map[string]time.Time
+	sweep  time.Time
+}
+
+// NewMemoryNonceCache create in memory nonce cache
+func NewMemoryNonceCache() NonceCache {
+	return &memoryNonceCache{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+func (cache *memoryNonceCache) Add(nonce string, expire time.Time) bool {
+	cache.Lock()
+	defer cache.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(cache.sweep) > time.Minute {
+		for key, value := range cache.nonces {
+			if value.Before(now) {
+				delete(cache.nonces, key)
+			}
+		}
+
+		cache.sweep = now
+	}
+
+	if value, ok := cache.nonces[nonce]; ok && value.After(now) {
+		return false
+	}
+
+	cache.nonces[nonce] = expire
+
+	return true
+}
+
+// defaultHMACBodyBytes signed body limit if neither HMACConfig nor the server Limits set one
+const defaultHMACBodyBytes = 10 << 20
+
+// HMACConfig HMAC signature verification config
+type HMACConfig struct {
+	Keys         map[string][]byte // key id -> shared key
+	MaxSkew      time.Duration     // max clock skew between client and server, default 5m
+	NonceCache   NonceCache        // default in memory cache
+	MaxBodyBytes int64             // max signed body size, default the server Limits.MaxBodyBytes or 10MB
+}
+
+// maxBodyBytes returns the signed body limit of request r
+func (config *HMACConfig) maxBodyBytes(r *http.Request) int64 {
+	if config.MaxBodyBytes > 0 {
+		return config.MaxBodyBytes
+	}
+
+	if server, ok := r.Context().Value(serverKey{}).(*serverImpl); ok && server.limits.MaxBodyBytes > 0 {
+		return server.limits.MaxBodyBytes
+	}
+
+	return defaultHMACBodyBytes
+}
+
+// readSignedBody read the request body up to the limit, the body is restored for the handler
+func (config *HMACConfig) readSignedBody(r *http.Request) ([]byte, error) {
+	maxBodyBytes := config.maxBodyBytes(r)
+
+	if r.ContentLength > maxBodyBytes {
+		return nil, xerrors.Wrapf(ErrBodyTooLarge, "signed body %d bytes exceeds %d", r.ContentLength, maxBodyBytes)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+
+	if err != nil {
+		return nil, xerrors.Wrapf(ErrMalformedRequest, "read signed body error: %s", err)
+	}
+
+	if int64(len(body)) > maxBodyBytes {
+		return nil, xerrors.Wrapf(ErrBodyTooLarge, "signed body exceeds %d bytes", maxBodyBytes)
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+type signerKey struct{}
+
+// SignerFromContext get key id verified by HMACMiddleware
+func SignerFromContext(ctx context.Context) (string, bool) {
+	keyID, ok := ctx.Value(signerKey{}).(string)
+	return keyID, ok
+}
+
+// HMACMiddleware verify requests signed by client.HMACAuth
+func HMACMiddleware(config HMACConfig) Middleware {
+
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = 5 * time.Minute
+	}
+
+	if config.NonceCache == nil {
+		config.NonceCache = NewMemoryNonceCache()
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		keyID := r.Header.Get(restrpc.HeaderKeyID)
+		timestamp := r.Header.Get(restrpc.HeaderTimestamp)
+		nonce := r.Header.Get(restrpc.HeaderNonce)
+		signature := r.Header.Get(restrpc.HeaderSignature)
+
+		if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+			WriteError(w, r, http.StatusUnauthorized, ErrSignatureMissing)
+			return
+		}
+
+		key, ok := config.Keys[keyID]
+
+		if !ok {
+			WriteError(w, r, http.StatusUnauthorized, ErrSignatureKey)
+			return
+		}
+
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+
+		if err != nil {
+			WriteError(w, r, http.StatusUnauthorized, ErrSignatureInvalid)
+			return
+		}
+
+		signTime := time.Unix(seconds, 0)
+
+		if skew := time.Since(signTime); skew > config.MaxSkew || skew < -config.MaxSkew {
+			WriteError(w, r, http.StatusUnauthorized, ErrSignatureSkew)
+			return
+		}
+
+		var body []byte
+
+		if r.Body != nil {
+			body, err = config.readSignedBody(r)
+
+			if err != nil {
+				WriteError(w, r, parameterStatus(err), err)
+				return
+			}
+		}
+
+		stringToSign := restrpc.StringToSign(r.Method, r.URL.EscapedPath(), r.URL.Query().Encode(), timestamp, nonce, body)
+
+		if !hmac.Equal([]byte(restrpc.Sign(key, stringToSign)), []byte(signature)) {
+			WriteError(w, r, http.StatusUnauthorized, ErrSignatureInvalid)
+			return
+		}
+
+		if !config.NonceCache.Add(keyID+":"+nonce, signTime.Add(config.MaxSkew)) {
+			WriteError(w, r, http.StatusUnauthorized, ErrSignatureReplay)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signerKey{}, keyID)))
+	}
+}
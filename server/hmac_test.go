@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/stretchr/testify/require"
+)
+
+// signedRequest create a json post request signed at signTime like client.HMACAuth
+func signedRequest(url string, body string, keyID string, key []byte, signTime time.Time, nonce string) *http.Request {
+	r := postJSON(url, body)
+
+	timestamp := strconv.FormatInt(signTime.Unix(), 10)
+
+	stringToSign := restrpc.StringToSign(r.Method, r.URL.EscapedPath(), r.URL.Query().Encode(), timestamp, nonce, []byte(body))
+
+	r.Header.Set(restrpc.HeaderKeyID, keyID)
+	r.Header.Set(restrpc.HeaderTimestamp, timestamp)
+	r.Header.Set(restrpc.HeaderNonce, nonce)
+	r.Header.Set(restrpc.HeaderSignature, restrpc.Sign(key, stringToSign))
+
+	return r
+}
+
+func TestHMACMiddleware(t *testing.T) {
+	key := []byte("secret")
+
+	handler := New().Handle("/test", &echoService{}, HMACMiddleware(HMACConfig{
+		Keys:    map[string][]byte{"service-a": key},
+		MaxSkew: time.Minute,
+	}))
+
+	now := time.Now()
+
+	w := serve(handler, signedRequest("/test/echo", `{"n":2}`, "service-a", key, now, "n1"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.JSONEq(t, `{"result":{"name":"","count":2}}`, w.Body.String())
+
+	// the same nonce is refused until the skew window passes
+	requireError(t, serve(handler, signedRequest("/test/echo", `{"n":2}`, "service-a", key, now, "n1")), http.StatusUnauthorized, ErrSignatureReplay.Code())
+
+	requireError(t, serve(handler, signedRequest("/test/echo", `{"n":2}`, "service-b", key, now, "n1")), http.StatusUnauthorized, ErrSignatureKey.Code())
+
+	// signatures inside the skew window in both directions are accepted
+	for i, offset := range []time.Duration{-50 * time.Second, 50 * time.Second} {
+		w = serve(handler, signedRequest("/test/echo", `{}`, "service-a", key, now.Add(offset), "skew"+strconv.Itoa(i)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	for i, offset := range []time.Duration{-2 * time.Minute, 2 * time.Minute} {
+		requireError(t, serve(handler, signedRequest("/test/echo", `{}`, "service-a", key, now.Add(offset), "late"+strconv.Itoa(i))), http.StatusUnauthorized, ErrSignatureSkew.Code())
+	}
+
+	// the body is part of the signature
+	r := signedRequest("/test/echo", `{"n":2}`, "service-a", key, now, "n2")
+	r.Body = httptest.NewRequest(http.MethodPost, "/test/echo", strings.NewReader(`{"n":3}`)).Body
+
+	requireError(t, serve(handler, r), http.StatusUnauthorized, ErrSignatureInvalid.Code())
+
+	r = signedRequest("/test/echo", `{}`, "service-a", key, now, "n3")
+	r.Header.Del(restrpc.HeaderNonce)
+
+	requireError(t, serve(handler, r), http.StatusUnauthorized, ErrSignatureMissing.Code())
+}
+
+func TestMemoryNonceCache(t *testing.T) {
+	cache := NewMemoryNonceCache()
+
+	require.True(t, cache.Add("a", time.Now().Add(time.Minute)))
+	require.False(t, cache.Add("a", time.Now().Add(time.Minute)))
+
+	// expired nonces may be used again
+	require.True(t, cache.Add("b", time.Now().Add(-time.Second)))
+	require.True(t, cache.Add("b", time.Now().Add(time.Minute)))
+}
+
+func TestHMACBodyLimit(t *testing.T) {
+	key := []byte("secret")
+
+	body := `{"name":"` + strings.Repeat("a", 64) + `"}`
+
+	// the config limit wins over the server limits
+	handler := New(WithLimits(Limits{MaxBodyBytes: 1024})).Handle("/test", &echoService{}, HMACMiddleware(HMACConfig{
+		Keys:         map[string][]byte{"service-a": key},
+		MaxBodyBytes: 32,
+	}))
+
+	requireError(t, serve(handler, signedRequest("/test/echo", body, "service-a", key, time.Now(), "n1")), http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Code())
+
+	// without a config limit the server limit applies, also to bodies of unknown length
+	handler = New(WithLimits(Limits{MaxBodyBytes: 32})).Handle("/test", &echoService{}, HMACMiddleware(HMACConfig{
+		Keys: map[string][]byte{"service-a": key},
+	}))
+
+	// the body is read before the signature is verified
+	r := signedRequest("/test/echo", body, "service-a", key, time.Now(), "n2")
+	r.ContentLength = -1
+	r.Header.Set(restrpc.HeaderSignature, "invalid")
+
+	requireError(t, serve(handler, r), http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Code())
+
+	w := serve(handler, signedRequest("/test/echo", `{"n":1}`, "service-a", key, time.Now(), "n3"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,403 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dynamicgo/xerrors"
+	"github.com/dynamicgo/xerrors/apierr"
+)
+
+// JWT errors
+var (
+	ErrTokenMissing   = apierr.New(-1001, "TOKEN_MISSING")
+	ErrTokenMalformed = apierr.New(-1002, "TOKEN_MALFORMED")
+	ErrTokenSignature = apierr.New(-1003, "TOKEN_SIGNATURE_INVALID")
+	ErrTokenExpired   = apierr.New(-1004, "TOKEN_EXPIRED")
+	ErrTokenNotActive = apierr.New(-1005, "TOKEN_NOT_ACTIVE")
+	ErrTokenAudience  = apierr.New(-1006, "TOKEN_AUDIENCE_INVALID")
+	ErrTokenIssuer    = apierr.New(-1007, "TOKEN_ISSUER_INVALID")
+)
+
+// Claims JWT claims
+type Claims map[string]interface{}
+
+// Subject .
+func (claims Claims) Subject() string {
+	subject, _ := claims["sub"].(string)
+	return subject
+}
+
+type claimsKey struct{}
+
+// ClaimsFromContext get claims verified by JWTMiddleware
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// JWTConfig JWT verification config
+type JWTConfig struct {
+	Keys        map[string]interface{} // kid -> []byte(HS256), *rsa.PublicKey(RS256) or *ecdsa.PublicKey(ES256), "" is the default key
+	JWKSFile    string                 // local JWKS file, reloaded when modified
+	JWKSRefresh time.Duration          // min interval between JWKS file modification checks, default 10s
+	Audience    string                 // expected aud claim, empty skip check
+	Issuer      string                 // expected iss claim, empty skip check
+	Leeway      time.Duration          // exp/nbf clock skew leeway
+}
+
+type jwtVerifier struct {
+	sync.Mutex
+	config    JWTConfig
+	jwks      map[string]interface{}
+	modTime   time.Time
+	checkTime time.Time
+}
+
+// JWTMiddleware verify bearer token, the claims are placed in the request context
+func JWTMiddleware(config JWTConfig) Middleware {
+
+	if config.JWKSRefresh <= 0 {
+		config.JWKSRefresh = 10 * time.Second
+	}
+
+	verifier := &jwtVerifier{
+		config: config,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+
+		authorization := r.Header.Get("Authorization")
+
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			WriteError(w, r, http.StatusUnauthorized, ErrTokenMissing)
+			return
+		}
+
+		claims, err := verifier.Verify(strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer ")))
+
+		if err != nil {
+			WriteError(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+}
+
+// Verify verify token and returns its claims
+func (verifier *jwtVerifier) Verify(token string) (Claims, error) {
+	segments := strings.Split(token, ".")
+
+	if len(segments) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var header jwtHeader
+
+	if err := verifier.decodeJSON(segments[0], &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := decodeSegment(segments[2])
+
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	key, ok := verifier.key(header.Kid)
+
+	if !ok {
+		return nil, xerrors.Wrapf(ErrTokenSignature, "unknown key id %s", header.Kid)
+	}
+
+	if !verifySignature(header.Alg, key, segments[0]+"."+segments[1], signature) {
+		return nil, ErrTokenSignature
+	}
+
+	var claims Claims
+
+	if err := verifier.decodeJSON(segments[1], &claims); err != nil {
+		return nil, err
+	}
+
+	return claims, verifier.checkClaims(claims)
+}
+
+func (verifier *jwtVerifier) decodeJSON(segment string, v interface{}) error {
+	buff, err := decodeSegment(segment)
+
+	if err != nil {
+		return ErrTokenMalformed
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(buff)))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(v); err != nil {
+		return ErrTokenMalformed
+	}
+
+	return nil
+}
+
+func (verifier *jwtVerifier) checkClaims(claims Claims) error {
+	now := time.Now()
+	leeway := verifier.config.Leeway
+
+	exp, ok, err := numericDate(claims, "exp")
+
+	if err != nil {
+		return err
+	}
+
+	if ok && now.After(exp.Add(leeway)) {
+		return ErrTokenExpired
+	}
+
+	nbf, ok, err := numericDate(claims, "nbf")
+
+	if err != nil {
+		return err
+	}
+
+	if ok && now.Add(leeway).Before(nbf) {
+		return ErrTokenNotActive
+	}
+
+	if verifier.config.Issuer != "" && claims["iss"] != verifier.config.Issuer {
+		return ErrTokenIssuer
+	}
+
+	if verifier.config.Audience != "" && !hasAudience(claims["aud"], verifier.config.Audience) {
+		return ErrTokenAudience
+	}
+
+	return nil
+}
+
+// numericDate returns the NumericDate claim name, ok is false if the claim is absent
+func numericDate(claims Claims, name string) (date time.Time, ok bool, err error) {
+	value, ok := claims[name]
+
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	number, ok := value.(json.Number)
+
+	if !ok {
+		return time.Time{}, false, xerrors.Wrapf(ErrTokenMalformed, "claim %s is not a number", name)
+	}
+
+	seconds, err := number.Float64()
+
+	if err != nil {
+		return time.Time{}, false, xerrors.Wrapf(ErrTokenMalformed, "claim %s is not a number", name)
+	}
+
+	// whole seconds and nanoseconds apart, nanoseconds since 1970 overflow int64 after 2262,
+	// dates beyond maxNumericDate seconds are clamped
+	seconds = math.Max(-maxNumericDate, math.Min(seconds, maxNumericDate))
+
+	whole, fraction := math.Modf(seconds)
+
+	return time.Unix(int64(whole), int64(fraction*1e9)), true, nil
+}
+
+// maxNumericDate bound of NumericDate seconds, far beyond any expiry while time.Unix stays exact
+const maxNumericDate = 1 << 53
+
+func hasAudience(value interface{}, audience string) bool {
+	switch aud := value.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, item := range aud {
+			if item == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func verifySignature(alg string, key interface{}, signingInput string, signature []byte) bool {
+	hashed := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+
+		if !ok {
+			return false
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "RS256":
+		publicKey, ok := key.(*rsa.PublicKey)
+
+		if !ok {
+			return false
+		}
+
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signature) == nil
+	case "ES256":
+		publicKey, ok := key.(*ecdsa.PublicKey)
+
+		if !ok || len(signature) != 64 {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+
+		return ecdsa.Verify(publicKey, hashed[:], r, s)
+	default:
+		return false
+	}
+}
+
+func (verifier *jwtVerifier) key(kid string) (interface{}, bool) {
+	if key, ok := verifier.config.Keys[kid]; ok {
+		return key, true
+	}
+
+	if verifier.config.JWKSFile == "" {
+		return nil, false
+	}
+
+	verifier.Lock()
+	defer verifier.Unlock()
+
+	key, ok := verifier.jwks[kid]
+
+	// reload on unknown kid or periodic check, keys may be rotated
+	if !ok || time.Since(verifier.checkTime) > verifier.config.JWKSRefresh {
+		verifier.reloadJWKS()
+		key, ok = verifier.jwks[kid]
+	}
+
+	return key, ok
+}
+
+func (verifier *jwtVerifier) reloadJWKS() {
+	if time.Since(verifier.checkTime) < time.Second && verifier.jwks != nil {
+		return
+	}
+
+	verifier.checkTime = time.Now()
+
+	info, err := os.Stat(verifier.config.JWKSFile)
+
+	if err != nil || info.ModTime().Equal(verifier.modTime) {
+		return
+	}
+
+	content, err := ioutil.ReadFile(verifier.config.JWKSFile)
+
+	if err != nil {
+		return
+	}
+
+	keys, err := ParseJWKS(content)
+
+	if err != nil {
+		return
+	}
+
+	verifier.jwks = keys
+	verifier.modTime = info.ModTime()
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parse JWKS content into kid -> key map, supports RSA, EC P-256 and oct keys
+func ParseJWKS(content []byte) (map[string]interface{}, error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return nil, xerrors.Wrapf(err, "parse jwks error")
+	}
+
+	keys := make(map[string]interface{})
+
+	for _, key := range jwks.Keys {
+		switch key.Kty {
+		case "RSA":
+			n, err1 := decodeSegment(key.N)
+			e, err2 := decodeSegment(key.E)
+
+			if err1 != nil || err2 != nil {
+				return nil, xerrors.Wrapf(ErrTokenMalformed, "invalid rsa key %s", key.Kid)
+			}
+
+			keys[key.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			x, err1 := decodeSegment(key.X)
+			y, err2 := decodeSegment(key.Y)
+
+			if err1 != nil || err2 != nil || key.Crv != "P-256" {
+				return nil, xerrors.Wrapf(ErrTokenMalformed, "invalid ec key %s", key.Kid)
+			}
+
+			keys[key.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		case "oct":
+			k, err := decodeSegment(key.K)
+
+			if err != nil {
+				return nil, xerrors.Wrapf(ErrTokenMalformed, "invalid oct key %s", key.Kid)
+			}
+
+			keys[key.Kid] = k
+		}
+	}
+
+	return keys, nil
+}
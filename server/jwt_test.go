@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type claimsService struct {
+}
+
+type claimsResult struct {
+	Subject string `json:"subject"`
+}
+
+func (service *claimsService) GetClaims(ctx context.Context, p *echoParam, r *claimsResult) error {
+	claims, _ := ClaimsFromContext(ctx)
+	r.Subject = claims.Subject()
+	return nil
+}
+
+func signToken(t *testing.T, alg string, kid string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hashed := sha256.Sum256([]byte(input))
+
+	var signature []byte
+
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, hashed[:])
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), hashed[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func getClaims(handler http.Handler, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/test/claims", nil)
+
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return serve(handler, r)
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	secret := []byte("secret")
+
+	handler := New().Handle("/test", &claimsService{}, JWTMiddleware(JWTConfig{
+		Keys: map[string]interface{}{
+			"hs": secret,
+			"rs": &rsaKey.PublicKey,
+			"es": &ecKey.PublicKey,
+		},
+		Audience: "restrpc",
+		Issuer:   "test",
+	}))
+
+	claims := map[string]interface{}{
+		"sub": "alice",
+		"aud": []string{"restrpc"},
+		"iss": "test",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+
+	for kid, key := range map[string]interface{}{"hs": secret, "rs": rsaKey, "es": ecKey} {
+		alg := map[string]string{"hs": "HS256", "rs": "RS256", "es": "ES256"}[kid]
+
+		w := getClaims(handler, signToken(t, alg, kid, key, claims))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.JSONEq(t, `{"result":{"subject":"alice"}}`, w.Body.String())
+	}
+
+	requireError(t, getClaims(handler, ""), http.StatusUnauthorized, ErrTokenMissing.Code())
+	requireError(t, getClaims(handler, signToken(t, "HS256", "hs", []byte("other"), claims)), http.StatusUnauthorized, ErrTokenSignature.Code())
+
+	expired := map[string]interface{}{"sub": "alice", "aud": "restrpc", "iss": "test", "exp": time.Now().Add(-time.Minute).Unix()}
+
+	requireError(t, getClaims(handler, signToken(t, "HS256", "hs", secret, expired)), http.StatusUnauthorized, ErrTokenExpired.Code())
+
+	// nanoseconds since 1970 overflow int64 after 2262
+	for _, exp := range []interface{}{int64(253402300799), 253402300799.5, 1e300} {
+		farFuture := map[string]interface{}{"sub": "alice", "aud": "restrpc", "iss": "test", "exp": exp}
+
+		w := getClaims(handler, signToken(t, "HS256", "hs", secret, farFuture))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	wrongAudience := map[string]interface{}{"sub": "alice", "aud": "other", "iss": "test"}
+
+	requireError(t, getClaims(handler, signToken(t, "HS256", "hs", secret, wrongAudience)), http.StatusUnauthorized, ErrTokenAudience.Code())
+
+	// NumericDate claims that are not numbers never expire the token
+	for _, malformed := range []map[string]interface{}{
+		{"sub": "alice", "aud": "restrpc", "iss": "test", "exp": "2030"},
+		{"sub": "alice", "aud": "restrpc", "iss": "test", "exp": nil},
+		{"sub": "alice", "aud": "restrpc", "iss": "test", "nbf": true},
+	} {
+		requireError(t, getClaims(handler, signToken(t, "HS256", "hs", secret, malformed)), http.StatusUnauthorized, ErrTokenMalformed.Code())
+	}
+}
+
+func TestJWTMiddlewareJWKS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "jwks.json")
+
+	writeKey := func(kid string, secret []byte) {
+		content, _ := json.Marshal(map[string]interface{}{
+			"keys": []map[string]string{
+				{"kty": "oct", "kid": kid, "k": base64.RawURLEncoding.EncodeToString(secret)},
+			},
+		})
+
+		require.NoError(t, ioutil.WriteFile(path, content, 0644))
+	}
+
+	writeKey("k1", []byte("first"))
+
+	handler := New().Handle("/test", &claimsService{}, JWTMiddleware(JWTConfig{
+		JWKSFile: path,
+	}))
+
+	claims := map[string]interface{}{"sub": "bob"}
+
+	w := getClaims(handler, signToken(t, "HS256", "k1", []byte("first"), claims))
+	require.JSONEq(t, `{"result":{"subject":"bob"}}`, w.Body.String())
+
+	// rotate key, the file modification time must change
+	time.Sleep(1100 * time.Millisecond)
+	writeKey("k2", []byte("second"))
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+
+	w = getClaims(handler, signToken(t, "HS256", "k2", []byte("second"), claims))
+	require.JSONEq(t, `{"result":{"subject":"bob"}}`, w.Body.String())
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/restrpc/validator"
+	"github.com/dynamicgo/xerrors"
+	"github.com/dynamicgo/xerrors/apierr"
+)
+
+// Request limit errors
+var (
+	ErrBodyTooLarge     = apierr.New(-1041, "REQUEST_BODY_TOO_LARGE")
+	ErrJSONTooDeep      = apierr.New(-1042, "REQUEST_JSON_TOO_DEEP")
+	ErrArrayTooLong     = apierr.New(-1043, "REQUEST_ARRAY_TOO_LONG")
+	ErrTooManyKeys      = apierr.New(-1044, "REQUEST_TOO_MANY_KEYS")
+	ErrTooManyParams    = apierr.New(-1045, "REQUEST_TOO_MANY_PARAMS")
+	ErrMalformedRequest = apierr.New(-1046, "REQUEST_MALFORMED")
+)
+
+// Default request limits of servers created without WithLimits
+const (
+	defaultMaxBodyBytes = 10 << 20
+	defaultMaxDepth     = 64
+)
+
+// Limits request size and shape limits, zero or negative disables the limit
+type Limits struct {
+	MaxBodyBytes   int64 // max request body bytes, checked after content-encoding decoding, see CompressionConfig.MaxDecodedBytes
+	MaxDepth       int   // max json nesting depth
+	MaxArrayLength int   // max json array length or repeated query values
+	MaxMapKeys     int   // max json object keys
+	MaxQueryParams int   // max query parameter values
+}
+
+// DefaultLimits returns the limits of servers created without WithLimits,
+// 10MB request bodies and json nesting depth 64
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBodyBytes: defaultMaxBodyBytes,
+		MaxDepth:     defaultMaxDepth,
+	}
+}
+
+// WithLimits replace the DefaultLimits, start from DefaultLimits() to keep them
+func WithLimits(limits Limits) Option {
+	return func(server *serverImpl) {
+		server.limits = limits
+	}
+}
+
+// parameterStatus returns the response status code of readParameter error
+func parameterStatus(err error) int {
+	if errors.Is(err, ErrUnsupportContentEncoding) {
+		return http.StatusUnsupportedMediaType
+	}
+
+	switch apierr.As(err, restrpc.ErrInternal).Code() {
+	case ErrBodyTooLarge.Code():
+		return http.StatusRequestEntityTooLarge
+	case ErrJSONTooDeep.Code(), ErrArrayTooLong.Code(), ErrTooManyKeys.Code(), ErrTooManyParams.Code(), ErrMalformedRequest.Code():
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// readBody read request body decoded with the compression config up to MaxBodyBytes,
+// encoded bodies are limited to the smaller of MaxBodyBytes and MaxDecodedBytes
+func (limits *Limits) readBody(r *http.Request, compression *CompressionConfig) ([]byte, error) {
+	maxBodyBytes := limits.MaxBodyBytes
+
+	if maxBodyBytes > 0 && r.ContentLength > maxBodyBytes {
+		return nil, xerrors.Wrapf(ErrBodyTooLarge, "request body %d bytes exceeds %d", r.ContentLength, maxBodyBytes)
+	}
+
+	body, encoded, err := decodeBody(r, compression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	if encoded && (maxBodyBytes <= 0 || compression.MaxDecodedBytes < maxBodyBytes) {
+		maxBodyBytes = compression.MaxDecodedBytes
+	}
+
+	var reader io.Reader = body
+
+	if maxBodyBytes > 0 {
+		reader = io.LimitReader(body, maxBodyBytes+1)
+	}
+
+	buff, err := ioutil.ReadAll(reader)
+
+	if err != nil && encoded {
+		return nil, xerrors.Wrapf(ErrMalformedRequest, "decode request body from %s error: %s", r.RequestURI, err)
+	}
+
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "unable read request body from %s", r.RequestURI)
+	}
+
+	if maxBodyBytes > 0 && int64(len(buff)) > maxBodyBytes {
+		return nil, xerrors.Wrapf(ErrBodyTooLarge, "request body exceeds %d bytes", maxBodyBytes)
+	}
+
+	return buff, nil
+}
+
+// checkQuery check query parameter count and repeated values
+func (limits *Limits) checkQuery(values url.Values) error {
+	total := 0
+
+	for key, value := range values {
+		if limits.MaxArrayLength > 0 && len(value) > limits.MaxArrayLength {
+			return xerrors.Wrapf(ErrArrayTooLong, "query parameter %s has %d values, max %d", key, len(value), limits.MaxArrayLength)
+		}
+
+		total += len(value)
+	}
+
+	if limits.MaxQueryParams > 0 && total > limits.MaxQueryParams {
+		return xerrors.Wrapf(ErrTooManyParams, "query has %d parameters, max %d", total, limits.MaxQueryParams)
+	}
+
+	return nil
+}
+
+// jsonLimits returns the json shape limits checked by the validator json parser
+func (limits *Limits) jsonLimits() validator.JSONLimits {
+	return validator.JSONLimits{
+		MaxDepth:       limits.MaxDepth,
+		MaxArrayLength: limits.MaxArrayLength,
+		MaxMapKeys:     limits.MaxMapKeys,
+	}
+}
+
+// jsonError map validator json parse errors to request errors
+func jsonError(err error) error {
+	switch {
+	case errors.Is(err, validator.ErrJSONDepth):
+		return xerrors.Wrapf(ErrJSONTooDeep, "%s", err)
+	case errors.Is(err, validator.ErrJSONLength):
+		return xerrors.Wrapf(ErrArrayTooLong, "%s", err)
+	case errors.Is(err, validator.ErrJSONKeys):
+		return xerrors.Wrapf(ErrTooManyKeys, "%s", err)
+	case errors.Is(err, validator.ErrJSONSyntax):
+		return xerrors.Wrapf(ErrMalformedRequest, "%s", err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimits(t *testing.T) {
+	handler := New(WithLimits(Limits{
+		MaxBodyBytes:   256,
+		MaxDepth:       3,
+		MaxArrayLength: 4,
+		MaxMapKeys:     4,
+		MaxQueryParams: 3,
+	})).Handle("/test", &echoService{})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		return serve(handler, postJSON("/test/echo", body))
+	}
+
+	w := post(`{"n":1,"extra":{"a":[1,2,3,4]}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"result":{"name":"","count":1}}`, w.Body.String())
+
+	requireError(t, post(`{"n":1,"extra":"`+strings.Repeat("a", 256)+`"}`), http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Code())
+	requireError(t, post(`{"n":1,"extra":{"a":{"b":{}}}}`), http.StatusBadRequest, ErrJSONTooDeep.Code())
+	requireError(t, post(`{"n":1,"extra":[1,2,3,4,5]}`), http.StatusBadRequest, ErrArrayTooLong.Code())
+	requireError(t, post(`{"n":1,"a":1,"b":2,"c":3,"d":4}`), http.StatusBadRequest, ErrTooManyKeys.Code())
+	requireError(t, post(`{"n":1,`), http.StatusBadRequest, ErrMalformedRequest.Code())
+
+	w = serve(handler, httptest.NewRequest(http.MethodGet, "/test/echo?n=1&a=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	requireError(t, serve(handler, httptest.NewRequest(http.MethodGet, "/test/echo?n=1&a=1&b=2&c=3", nil)), http.StatusBadRequest, ErrTooManyParams.Code())
+}
+
+func TestMalformedJSON(t *testing.T) {
+	// syntax errors are client errors without any limits set
+	handler := New().Handle("/test", &echoService{})
+
+	for _, body := range []string{`{"n":1,`, `{"n":1} x`, `{"n":"\u00"}`} {
+		requireError(t, serve(handler, postJSON("/test/echo", body)), http.StatusBadRequest, ErrMalformedRequest.Code())
+	}
+}
+
+func TestDefaultLimits(t *testing.T) {
+	handler := New().Handle("/test", &echoService{})
+
+	requireError(t, serve(handler, postJSON("/test/echo", `{"n":1,"extra":`+strings.Repeat("[", defaultMaxDepth)+strings.Repeat("]", defaultMaxDepth)+`}`)), http.StatusBadRequest, ErrJSONTooDeep.Code())
+	requireError(t, serve(handler, postJSON("/test/echo", `{"n":1,"extra":"`+strings.Repeat("a", defaultMaxBodyBytes)+`"}`)), http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Code())
+
+	// zero limits opt out
+	handler = New(WithLimits(Limits{})).Handle("/test", &echoService{})
+
+	w := serve(handler, postJSON("/test/echo", `{"n":1,"extra":`+strings.Repeat("[", defaultMaxDepth)+strings.Repeat("]", defaultMaxDepth)+`}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/restrpc/validator"
+	"github.com/dynamicgo/xerrors/apierr"
+)
+
+// WithProblemDetails write error responses as RFC 7807 restrpc.ContentTypeProblem documents,
+// without it only requests accepting restrpc.ContentTypeProblem get problem details
+func WithProblemDetails() Option {
+	return func(server *serverImpl) {
+		server.problemDetails = true
+	}
+}
+
+// acceptsProblem check whether the error response of r is written as problem details, r is nil for Fail
+func (server *serverImpl) acceptsProblem(r *http.Request) bool {
+	return server.problemDetails || (r != nil && restrpc.AcceptsProblem(r.Header.Get("Accept")))
+}
+
+// newProblem create problem details of err, services may return *restrpc.Problem to set
+// the type, status or field errors, the missing members are filled from the request and apierr
+func newProblem(r *http.Request, code int, err error) *restrpc.Problem {
+	problem := &restrpc.Problem{}
+
+	var declared *restrpc.Problem
+
+	if errors.As(err, &declared) {
+		*problem = *declared
+	}
+
+	apiErr := apierr.As(err, restrpc.ErrInternal)
+
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+
+	if problem.Status == 0 {
+		problem.Status = problemStatus(code, err)
+	}
+
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+
+	if problem.Detail == "" {
+		problem.Detail = apiErr.Error()
+	}
+
+	if problem.ErrCode == 0 {
+		problem.ErrCode = apiErr.Code()
+	}
+
+	if problem.Instance == "" && r != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	var fieldErr *restrpc.FieldError
+
+	if len(problem.Errors) == 0 && errors.As(err, &fieldErr) {
+		problem.Errors = []*restrpc.FieldError{fieldErr}
+	}
+
+	return problem
+}
+
+// problemStatus returns the problem status of a code response, validation failures are client errors,
+// other envelopes keep the status of earlier versions
+func problemStatus(code int, err error) int {
+	var fieldErr *restrpc.FieldError
+
+	if code == http.StatusInternalServerError && (errors.As(err, &fieldErr) || errors.Is(err, validator.ErrJSONSyntax)) {
+		return http.StatusBadRequest
+	}
+
+	return code
+}
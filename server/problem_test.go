@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/stretchr/testify/require"
+)
+
+type problemParam struct {
+	ID    string `rest:"required"`
+	Count int    `rest:"n"`
+}
+
+type problemService struct {
+}
+
+func (service *problemService) GetFail(p *problemParam, r *echoResult) error {
+	return errEnvelope
+}
+
+func (service *problemService) PutConflict(p *problemParam, r *echoResult) error {
+	return &restrpc.Problem{
+		Type:    "https://example.com/problems/conflict",
+		Status:  http.StatusConflict,
+		ErrCode: -9,
+		Detail:  "version conflict",
+	}
+}
+
+func getProblem(t *testing.T, handler http.Handler, url string, accept string) (*httptest.ResponseRecorder, *restrpc.Problem) {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	r.Header.Set("Accept", accept)
+
+	w := serve(handler, r)
+
+	var problem restrpc.Problem
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+
+	return w, &problem
+}
+
+func TestProblemDetails(t *testing.T) {
+	handler := New(WithProblemDetails()).Handle("/test", &problemService{})
+
+	w, problem := getProblem(t, handler, "/test/fail?id=1", "")
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, restrpc.ContentTypeProblem, w.Header().Get("Content-Type"))
+	require.Equal(t, &restrpc.Problem{
+		Type:     "about:blank",
+		Title:    "Internal Server Error",
+		Status:   http.StatusInternalServerError,
+		Detail:   "boom",
+		Instance: "/test/fail",
+		ErrCode:  -7,
+	}, problem)
+
+	// validation failures are client errors
+	w, problem = getProblem(t, handler, "/test/fail", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, http.StatusBadRequest, problem.Status)
+	require.Equal(t, "Bad Request", problem.Title)
+	require.Equal(t, []*restrpc.FieldError{{Field: "id", Detail: "expect param id"}}, problem.Errors)
+
+	w, problem = getProblem(t, handler, "/test/fail?id=1&n=abc", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Len(t, problem.Errors, 1)
+	require.Equal(t, "n", problem.Errors[0].Field)
+	require.Contains(t, problem.Errors[0].Detail, "abc")
+
+	for _, body := range []string{`{"id":"1","n":"x"}`, `{"id":"1","n":[1]}`, `{"id":`} {
+		r := postJSON("/test/conflict", body)
+		r.Method = http.MethodPut
+
+		require.Equal(t, http.StatusBadRequest, serve(handler, r).Code, body)
+	}
+
+	r := postJSON("/test/conflict", `{"id":"1"}`)
+	r.Method = http.MethodPut
+
+	w = serve(handler, r)
+
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.JSONEq(t, `{
+		"type": "https://example.com/problems/conflict",
+		"title": "Conflict",
+		"status": 409,
+		"detail": "version conflict",
+		"instance": "/test/conflict",
+		"code": -9
+	}`, w.Body.String())
+}
+
+func TestProblemNegotiation(t *testing.T) {
+	handler := New().Handle("/test", &problemService{})
+
+	w, _ := getProblem(t, handler, "/test/fail?id=1", "application/json")
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	w, _ = getProblem(t, handler, "/test/fail?id=1", "application/json, application/problem+json;q=0")
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	w, _ = getProblem(t, handler, "/test/fail?id=1", "application/json, application/problem+json")
+	require.Equal(t, restrpc.ContentTypeProblem, w.Header().Get("Content-Type"))
+
+	// validation failures are reported as 400 in problem details only
+	w = serve(handler, httptest.NewRequest(http.MethodGet, "/test/fail", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	w, problem := getProblem(t, handler, "/test/fail", restrpc.ContentTypeProblem)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, http.StatusBadRequest, problem.Status)
+}
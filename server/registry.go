@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/dynamicgo/restrpc/registry"
+	"github.com/dynamicgo/slf4go"
+	"github.com/dynamicgo/xerrors"
+)
+
+// Registration file registry registration
+type Registration struct {
+	File    string // registry file path, JSON or YAML by extension
+	Service string // service name
+	URL     string // advertised url, default <scheme>://<listen address>, unspecified hosts resolve to an interface address
+	Weight  int    // endpoint weight
+}
+
+// Register write the registration into the registry file
+func (registration *Registration) Register() error {
+	return registry.Register(registration.File, registration.Service, registry.Entry{
+		URL:    registration.URL,
+		Weight: registration.Weight,
+	})
+}
+
+// Deregister remove the registration from the registry file
+func (registration *Registration) Deregister() error {
+	return registry.Deregister(registration.File, registration.Service, registration.URL)
+}
+
+// ListenAndServe listen on httpServer.Addr, register into the registries once listening
+// and deregister when the server is shutdown or stop serving. The server is served with TLS
+// and advertised as https if httpServer.TLSConfig is set, the registrations are not modified
+func ListenAndServe(httpServer *http.Server, registrations ...*Registration) error {
+
+	logger := slf4go.Get("server")
+
+	listener, err := net.Listen("tcp", httpServer.Addr)
+
+	if err != nil {
+		return err
+	}
+
+	var once sync.Once
+
+	var registered []*Registration
+
+	deregister := func() {
+		once.Do(func() {
+			for _, registration := range registered {
+				if err := registration.Deregister(); err != nil {
+					logger.ErrorF("deregister %s from %s error: %s", registration.Service, registration.File, err)
+				}
+			}
+		})
+	}
+
+	defer deregister()
+
+	httpServer.RegisterOnShutdown(deregister)
+
+	scheme := "http"
+
+	if httpServer.TLSConfig != nil {
+		scheme = "https"
+	}
+
+	for _, registration := range registrations {
+		advertised := *registration
+
+		if advertised.URL == "" {
+			advertised.URL, err = advertisedURL(scheme, listener.Addr())
+
+			if err != nil {
+				listener.Close()
+				return err
+			}
+		}
+
+		if err := advertised.Register(); err != nil {
+			listener.Close()
+			return err
+		}
+
+		registered = append(registered, &advertised)
+	}
+
+	if httpServer.TLSConfig != nil {
+		return httpServer.ServeTLS(listener, "", "")
+	}
+
+	return httpServer.Serve(listener)
+}
+
+// advertisedURL returns the url peers dial to reach addr, the unspecified address
+// of ":8080" is replaced with the first non loopback interface address
+func advertisedURL(scheme string, addr net.Addr) (string, error) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+
+	if !ok || !tcpAddr.IP.IsUnspecified() {
+		return fmt.Sprintf("%s://%s", scheme, addr), nil
+	}
+
+	host, err := routableHost()
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))), nil
+}
+
+// routableHost returns the first non loopback interface address, preferring IPv4, or the loopback address
+func routableHost() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+
+	if err != nil {
+		return "", xerrors.Wrapf(err, "list interface addresses error")
+	}
+
+	var fallback string
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP.String(), nil
+		}
+
+		if fallback == "" {
+			fallback = ipNet.IP.String()
+		}
+	}
+
+	if fallback == "" {
+		fallback = "127.0.0.1"
+	}
+
+	return fallback, nil
+}
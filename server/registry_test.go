@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dynamicgo/restrpc/registry"
+	"github.com/stretchr/testify/require"
+)
+
+// waitEntries wait for the registry file entries of service
+func waitEntries(t *testing.T, path string, service string) []registry.Entry {
+	var entries []registry.Entry
+
+	for deadline := time.Now().Add(2 * time.Second); len(entries) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+
+		if services, err := registry.Load(path); err == nil {
+			entries = services[service]
+		}
+	}
+
+	require.Len(t, entries, 1)
+
+	return entries
+}
+
+func TestListenAndServeRegistration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registration")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "registry.json")
+
+	httpServer := &http.Server{Addr: ":0", Handler: New().Handle("/test", &echoService{})}
+
+	registration := &Registration{File: path, Service: "echo"}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- ListenAndServe(httpServer, registration)
+	}()
+
+	entries := waitEntries(t, path, "echo")
+
+	require.True(t, strings.HasPrefix(entries[0].URL, "http://"), entries[0].URL)
+	require.NotContains(t, entries[0].URL, "[::]")
+	require.NotContains(t, entries[0].URL, "0.0.0.0")
+
+	// the caller registration is not modified
+	require.Equal(t, "", registration.URL)
+
+	resp, err := http.Get(entries[0].URL + "/test/echo?name=a")
+	require.NoError(t, err)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	require.NoError(t, err)
+	require.JSONEq(t, `{"result":{"name":"a","count":0}}`, string(body))
+
+	require.NoError(t, httpServer.Shutdown(context.Background()))
+	require.Equal(t, http.ErrServerClosed, <-done)
+
+	services, err := registry.Load(path)
+	require.NoError(t, err)
+	require.Empty(t, services["echo"])
+}
+
+func TestListenAndServeTLSRegistration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registration")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "registry.json")
+
+	// borrow the httptest certificate and a client trusting it
+	testServer := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsConfig, client := testServer.TLS, testServer.Client()
+	testServer.Close()
+
+	httpServer := &http.Server{Addr: "127.0.0.1:0", Handler: New().Handle("/test", &echoService{}), TLSConfig: tlsConfig}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- ListenAndServe(httpServer, &Registration{File: path, Service: "echo"})
+	}()
+
+	entries := waitEntries(t, path, "echo")
+
+	require.True(t, strings.HasPrefix(entries[0].URL, "https://127.0.0.1:"), entries[0].URL)
+
+	resp, err := client.Get(entries[0].URL + "/test/echo?name=b")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, httpServer.Shutdown(context.Background()))
+	require.Equal(t, http.ErrServerClosed, <-done)
+}
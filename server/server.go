@@ -1,13 +1,15 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dynamicgo/restrpc"
 	"github.com/dynamicgo/xerrors/apierr"
@@ -23,9 +25,12 @@ import (
 
 // Errors
 var (
-	ErrUnsupportContentType = errors.New("unsupport content-type")
+	ErrUnsupportContentType     = errors.New("unsupport content-type")
+	ErrUnsupportContentEncoding = errors.New("unsupport content-encoding")
 )
 
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 var methods = map[string]string{
 	"Get":     http.MethodGet,
 	"Put":     http.MethodPut,
@@ -44,9 +49,20 @@ type R map[string]interface{}
 // Middleware server middleware
 type Middleware func(resp http.ResponseWriter, req *http.Request, next http.Handler)
 
+// Route registered route
+type Route struct {
+	Method      string                `json:"method"`                // http method
+	Path        string                `json:"path"`                  // route path
+	Service     string                `json:"service"`               // service type
+	Name        string                `json:"name"`                  // service method name
+	Requirement *Requirement          `json:"requirement,omitempty"` // access requirement declared by Permissions
+	Parameters  []validator.Parameter `json:"parameters,omitempty"`  // input parameters
+}
+
 // Server rpc server
 type Server interface {
 	Handle(path string, service interface{}, middleware ...Middleware) Server
+	Routes() []Route
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 	Success(w http.ResponseWriter, result interface{}) error
 	Fail(w http.ResponseWriter, code int, cause error) error
@@ -63,19 +79,72 @@ func (handler *middlewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 
 type serverImpl struct {
 	slf4go.Logger
-	router *httprouter.Router
+	router         *httprouter.Router
+	routes         []Route
+	cors           *CORSConfig
+	compression    *CompressionConfig
+	limits         Limits
+	envelope       restrpc.Envelope
+	problemDetails bool
 }
 
+// Option server option
+type Option func(server *serverImpl)
+
 // New create new Server
-func New() Server {
-	return &serverImpl{
-		Logger: slf4go.Get("server"),
-		router: httprouter.New(),
+func New(options ...Option) Server {
+	server := &serverImpl{
+		Logger:   slf4go.Get("server"),
+		router:   httprouter.New(),
+		envelope: restrpc.ClassicEnvelope{},
+		limits:   DefaultLimits(),
+	}
+
+	for _, option := range options {
+		option(server)
 	}
+
+	return server
 }
 
+type serverKey struct{}
+
 func (server *serverImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	server.router.ServeHTTP(w, r)
+
+	if server.cors != nil && server.handleCORS(w, r) {
+		return
+	}
+
+	w.Header().Set(restrpc.HeaderEnvelope, server.negotiateEnvelope(r).Name())
+
+	if server.compression != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			cw := &compressResponseWriter{
+				ResponseWriter: w,
+				config:         server.compression,
+				encoding:       encoding,
+			}
+
+			defer cw.Close()
+
+			w = cw
+		}
+	}
+
+	server.router.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), serverKey{}, server)))
+}
+
+// WriteError write error response with the server serving the request, used by middlewares
+func WriteError(w http.ResponseWriter, r *http.Request, code int, cause error) error {
+	server, ok := r.Context().Value(serverKey{}).(*serverImpl)
+
+	if !ok {
+		server = New().(*serverImpl)
+	}
+
+	return server.writeResponse(w, r, nil, code, cause)
 }
 
 func (server *serverImpl) checkInputType(paramT reflect.Type) bool {
@@ -94,9 +163,18 @@ func (server *serverImpl) Handle(path string, service interface{}, middleware ..
 
 	serviceT := reflect.TypeOf(service)
 
+	var permissions map[string]Requirement
+
+	if declared, ok := service.(Permissions); ok {
+		permissions = declared.Permissions()
+	}
+
+	var routes []Route
+
+	var handlers []http.Handler
+
 	for i := 0; i < serviceT.NumMethod(); i++ {
 		method := serviceT.Method(i)
-		println(method.Name)
 
 		httpMethod, ok := server.validMethod(method.Name)
 
@@ -105,21 +183,29 @@ func (server *serverImpl) Handle(path string, service interface{}, middleware ..
 			continue
 		}
 
-		if method.Type.NumIn() != 3 {
+		withContext := method.Type.NumIn() == 4 && method.Type.In(1) == contextType
+
+		if method.Type.NumIn() != 3 && !withContext {
 			server.DebugF("[%s] skip invalid method %s,input parameters != 2 (%d)", serviceT, method.Name, method.Type.NumIn())
 			continue
 		}
 
-		if !server.checkInputType(method.Type.In(1)) {
-			server.DebugF("[%s] skip invalid method %s param 1 %s , parameter must be struct ptr", serviceT, method.Name, method.Type.In(1))
+		offset := method.Type.NumIn() - 2
+
+		if !server.checkInputType(method.Type.In(offset)) {
+			server.DebugF("[%s] skip invalid method %s param 1 %s , parameter must be struct ptr", serviceT, method.Name, method.Type.In(offset))
 			continue
 		}
 
-		if !server.checkInputType(method.Type.In(2)) {
-			server.DebugF("[%s] skip invalid method %s param 2 %s , parameter must be struct ptr", serviceT, method.Name, method.Type.In(2))
+		if !server.checkInputType(method.Type.In(offset + 1)) {
+			server.DebugF("[%s] skip invalid method %s param 2 %s , parameter must be struct ptr", serviceT, method.Name, method.Type.In(offset+1))
 			continue
 		}
 
+		if err := validator.Check(method.Type.In(offset)); err != nil {
+			panic(fmt.Sprintf("service %s method %s invalid parameter %s: %s", serviceT, method.Name, method.Type.In(offset), err))
+		}
+
 		if method.Type.NumOut() != 1 {
 			server.DebugF("[%s] skip invalid method %s,output parameters != 1 ", serviceT, method.Name)
 			continue
@@ -132,20 +218,62 @@ func (server *serverImpl) Handle(path string, service interface{}, middleware ..
 			continue
 		}
 
-		handler := server.packageHandlers(server.createHandle(service, &method), middleware...)
+		var requirement *Requirement
+
+		if declared, ok := permissions[method.Name]; ok {
+			requirement = &declared
+		}
+
+		handler := server.packageHandlers(server.createHandle(service, method, withContext, requirement), middleware...)
 
 		name := strings.TrimPrefix(strings.ToLower(method.Name), strings.ToLower(httpMethod))
 
 		methodPath := fmt.Sprintf("%s/%s", path, name)
 
-		server.router.Handler(httpMethod, methodPath, handler)
+		handlers = append(handlers, handler)
 
-		server.InfoF("[%s] find valid http %s method %s register handle %s", serviceT, httpMethod, method.Name, methodPath)
+		routes = append(routes, Route{
+			Method:      httpMethod,
+			Path:        methodPath,
+			Service:     serviceT.String(),
+			Name:        method.Name,
+			Requirement: requirement,
+			Parameters:  validator.Parameters(method.Type.In(offset)),
+		})
+	}
+
+	// a requirement of a misspelled or skipped method would leave the real method public
+	for name := range permissions {
+		if !hasRoute(routes, name) {
+			panic(fmt.Sprintf("service %s permissions declare unknown method %s", serviceT, name))
+		}
+	}
+
+	for i, route := range routes {
+		server.router.Handler(route.Method, route.Path, handlers[i])
+
+		server.routes = append(server.routes, route)
+
+		server.InfoF("[%s] find valid http %s method %s register handle %s", serviceT, route.Method, route.Name, route.Path)
 	}
 
 	return server
 }
 
+func hasRoute(routes []Route, name string) bool {
+	for _, route := range routes {
+		if route.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (server *serverImpl) Routes() []Route {
+	return append([]Route(nil), server.routes...)
+}
+
 func (server *serverImpl) packageHandlers(handler http.Handler, middlewares ...Middleware) http.Handler {
 
 	next := handler
@@ -170,60 +298,104 @@ func (server *serverImpl) validMethod(name string) (string, bool) {
 	return "", false
 }
 
-func (server *serverImpl) createHandle(service interface{}, method *reflect.Method) http.Handler {
+func (server *serverImpl) createHandle(service interface{}, method reflect.Method, withContext bool, requirement *Requirement) http.Handler {
 
 	serviceValue := reflect.ValueOf(service)
 
+	inputT := method.Type.In(method.Type.NumIn() - 2)
+	outputT := method.Type.In(method.Type.NumIn() - 1)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		input, err := server.readParameter(r, method.Type.In(1))
+		if requirement != nil {
+			if code, err := requirement.authorize(r); err != nil {
+				server.writeResponse(w, r, nil, code, err)
+				return
+			}
+		}
+
+		ctx, cancel := server.requestContext(r)
+		defer cancel()
+
+		input, err := server.readParameter(r, inputT)
 
 		if err != nil {
-			server.writeResponse(w, nil, http.StatusInternalServerError, err)
+			server.writeResponse(w, r, nil, parameterStatus(err), err)
 			return
 		}
 
-		output := reflect.New(method.Type.In(2))
+		output := reflect.New(outputT.Elem())
+
+		params := []reflect.Value{serviceValue}
 
-		params := []reflect.Value{
-			serviceValue,
-			input,
-			output,
+		if withContext {
+			params = append(params, reflect.ValueOf(ctx))
 		}
 
+		params = append(params, input, output)
+
 		results := method.Func.Call(params)
 
 		err, ok := results[0].Interface().(error)
 
-		if !ok {
+		if !ok && !results[0].IsNil() {
 			panic(fmt.Sprintf("filter service %s RESTful method %s error,result must be error", reflect.TypeOf(service), method.Name))
 		}
 
 		if err != nil {
-			server.writeResponse(w, nil, http.StatusInternalServerError, err)
+			server.writeResponse(w, r, nil, http.StatusInternalServerError, err)
 		} else {
-			server.writeResponse(w, R{
-				"result": output.Interface(),
-			}, http.StatusOK, nil)
+			server.writeResponse(w, r, output.Interface(), http.StatusOK, nil)
 		}
 
 	})
 }
 
-func (server *serverImpl) writeResponse(w http.ResponseWriter, r R, code int, err error) error {
+// requestContext create service method context with the caller deadline transmitted by restrpc.HeaderTimeout
+func (server *serverImpl) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
 
-	if err != nil {
-		apiErr := apierr.As(err, restrpc.ErrInternal)
-		r["code"] = apiErr.Code()
-		r["errmsg"] = apiErr.Error()
+	ctx := r.Context()
+
+	value := r.Header.Get(restrpc.HeaderTimeout)
+
+	if value == "" {
+		return context.WithCancel(ctx)
 	}
 
-	buff, err := json.Marshal(r)
+	timeout, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil || timeout <= 0 {
+		server.WarnF("invalid %s header value %s", restrpc.HeaderTimeout, value)
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+}
+
+// writeResponse write result or err with the envelope negotiated by ServeHTTP,
+// errors are written as problem details if the server or the request r asks for them
+func (server *serverImpl) writeResponse(w http.ResponseWriter, r *http.Request, result interface{}, code int, err error) error {
+
+	contentType := "application/json"
+
+	var response interface{}
+
+	if err != nil && server.acceptsProblem(r) {
+		problem := newProblem(r, code, err)
+		response, code, contentType = problem, problem.Status, restrpc.ContentTypeProblem
+	} else if err != nil {
+		response = server.responseEnvelope(w).Failure(apierr.As(err, restrpc.ErrInternal))
+	} else {
+		response = server.responseEnvelope(w).Success(result)
+	}
+
+	buff, err := json.Marshal(response)
 
 	if err != nil {
-		return xerrors.Wrapf(err, "marshal response %v err %s", r, err)
+		return xerrors.Wrapf(err, "marshal response %v err %s", response, err)
 	}
 
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(code)
 	_, err = w.Write(buff)
 
@@ -235,12 +407,10 @@ func (server *serverImpl) writeResponse(w http.ResponseWriter, r R, code int, er
 }
 
 func (server *serverImpl) Success(w http.ResponseWriter, result interface{}) error {
-	return server.writeResponse(w, R{
-		"result": result,
-	}, http.StatusOK, nil)
+	return server.writeResponse(w, nil, result, http.StatusOK, nil)
 }
 func (server *serverImpl) Fail(w http.ResponseWriter, code int, cause error) error {
-	return server.writeResponse(w, nil, code, cause)
+	return server.writeResponse(w, nil, nil, code, cause)
 }
 
 func (server *serverImpl) readParameter(r *http.Request, paramT reflect.Type) (reflect.Value, error) {
@@ -248,7 +418,13 @@ func (server *serverImpl) readParameter(r *http.Request, paramT reflect.Type) (r
 	var reader restrpc.Reader
 
 	if r.Method == http.MethodGet || r.Method == http.MethodDelete {
-		reader = validator.NewQueryReader(r.URL.Query())
+		query := r.URL.Query()
+
+		if err := server.limits.checkQuery(query); err != nil {
+			return reflect.Value{}, err
+		}
+
+		reader = validator.NewQueryReader(query)
 	} else {
 
 		contentType := r.Header.Get("Content-type")
@@ -257,16 +433,16 @@ func (server *serverImpl) readParameter(r *http.Request, paramT reflect.Type) (r
 			return reflect.Value{}, xerrors.Wrapf(ErrUnsupportContentType, "restrpc only support application/json content-type")
 		}
 
-		buff, err := ioutil.ReadAll(r.Body)
+		buff, err := server.limits.readBody(r, server.compression)
 
 		if err != nil {
-			return reflect.Value{}, xerrors.Wrapf(err, "unable read request body from %s", r.RequestURI)
+			return reflect.Value{}, err
 		}
 
-		reader, err = validator.NewJSONReader(buff)
+		reader, err = validator.NewJSONReaderWithLimits(buff, server.limits.jsonLimits())
 
 		if err != nil {
-			return reflect.Value{}, xerrors.Wrapf(err, "parse request %s json body error", r.RequestURI)
+			return reflect.Value{}, xerrors.Wrapf(jsonError(err), "parse request %s json body error", r.RequestURI)
 		}
 	}
 
@@ -276,5 +452,17 @@ func (server *serverImpl) readParameter(r *http.Request, paramT reflect.Type) (r
 		return reflect.Value{}, err
 	}
 
-	return values[0], nil
+	value := values[0]
+
+	if value.Kind() != reflect.Ptr {
+		if !value.CanAddr() {
+			ptr := reflect.New(value.Type())
+			ptr.Elem().Set(value)
+			return ptr, nil
+		}
+
+		value = value.Addr()
+	}
+
+	return value, nil
 }
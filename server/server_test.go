@@ -2,8 +2,13 @@ package server
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 type A struct {
@@ -27,14 +32,109 @@ func (a *A) PostB(p *Param, r *Result) error {
 	return nil
 }
 
-func init() {
-	server := New()
-	server.Handle("/", &A{})
-	http.ListenAndServe(":8080", server)
+func TestHandle(t *testing.T) {
+	handler := New()
+	handler.Handle("/", &A{})
+
+	testServer := httptest.NewServer(handler)
+	defer testServer.Close()
+
+	routes := handler.Routes()
+
+	require.Len(t, routes, 3)
+
+	// every method of A registered at the root path is served
+	for _, route := range routes {
+		request, err := http.NewRequest(route.Method, testServer.URL+route.Path, strings.NewReader("{}"))
+		require.NoError(t, err)
+
+		request.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, route.Path)
+		require.JSONEq(t, `{"result":{}}`, string(body))
+	}
 }
 
-func TestHandle(t *testing.T) {
+type echoService struct {
+}
+
+type echoParam struct {
+	Name  string
+	Count int `rest:"n"`
+}
+
+type echoResult struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
 
+func (service *echoService) GetEcho(p *echoParam, r *echoResult) error {
+	r.Name = p.Name
+	r.Count = p.Count
+	return nil
+}
+
+func (service *echoService) PostEcho(p *echoParam, r *echoResult) error {
+	return service.GetEcho(p, r)
+}
+
+type nodeParam struct {
+	Name string
+	Next *nodeParam
+}
+
+type nodeService struct {
+}
+
+func (service *nodeService) PostNode(p *nodeParam, r *echoResult) error {
+	for ; p != nil; p = p.Next {
+		r.Name += p.Name
+	}
+
+	return nil
+}
+
+func TestHandleRecursive(t *testing.T) {
+	handler := New().Handle("/test", &nodeService{})
+
+	require.Len(t, handler.Routes(), 1)
+
+	w := serve(handler, postJSON("/test/node", `{"name":"a","next":{"name":"b"}}`))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"result":{"name":"ab","count":0}}`, w.Body.String())
+}
+
+// serve handle request r and returns the recorded response
+func serve(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// postJSON create a json body request
+func postJSON(url string, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+// requireError check the status and the apierr code of an error response
+func requireError(t *testing.T, w *httptest.ResponseRecorder, status int, code int) {
+	var response struct {
+		Code int `json:"code"`
+	}
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+	require.Equal(t, status, w.Code, w.Body.String())
+	require.Equal(t, code, response.Code, w.Body.String())
 }
 
 func printResult(v interface{}) string {
@@ -42,3 +142,30 @@ func printResult(v interface{}) string {
 
 	return string(val)
 }
+
+type collisionParam struct {
+	Name  string `rest:"name"`
+	Alias string `rest:"name"`
+}
+
+type collisionService struct {
+}
+
+func (service *collisionService) PostCollision(p *collisionParam, r *echoResult) error {
+	return nil
+}
+
+func (service *collisionService) Permissions() map[string]Requirement {
+	return map[string]Requirement{
+		"PostCollision": {Roles: []string{"admin"}},
+	}
+}
+
+func TestHandleInvalidParameter(t *testing.T) {
+	// the invalid parameter is reported, not the permissions of the method
+	defer func() {
+		require.Contains(t, recover(), "service *server.collisionService method PostCollision invalid parameter *server.collisionParam")
+	}()
+
+	New().Handle("/test", &collisionService{})
+}
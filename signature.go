@@ -0,0 +1,39 @@
+package restrpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// HMAC request signing headers
+const (
+	HeaderKeyID     = "X-Restrpc-Key-Id"
+	HeaderTimestamp = "X-Restrpc-Timestamp"
+	HeaderNonce     = "X-Restrpc-Nonce"
+	HeaderSignature = "X-Restrpc-Signature"
+)
+
+// StringToSign canonical request string signed by HMAC auth:
+// method, escaped path, encoded query, timestamp, nonce and hex sha256 of body joined by '\n'
+func StringToSign(method string, path string, query string, timestamp string, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		query,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// Sign HMAC-SHA256 sign the string, returns hex signature
+func Sign(key []byte, stringToSign string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
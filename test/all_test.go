@@ -2,10 +2,14 @@ package test
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/dynamicgo/restrpc/server"
+	"github.com/stretchr/testify/require"
 )
 
 type A struct {
@@ -29,14 +33,34 @@ func (a *A) PostB(p *Param, r *Result) error {
 	return nil
 }
 
-func init() {
-	server := server.New()
-	server.Handle("/", &A{})
-	http.ListenAndServe(":8080", server)
-}
-
 func TestHandle(t *testing.T) {
+	handler := server.New()
+	handler.Handle("/", &A{})
+
+	testServer := httptest.NewServer(handler)
+	defer testServer.Close()
+
+	routes := handler.Routes()
+
+	require.Len(t, routes, 3)
+
+	// every method of A registered at the root path is served
+	for _, route := range routes {
+		request, err := http.NewRequest(route.Method, testServer.URL+route.Path, strings.NewReader("{}"))
+		require.NoError(t, err)
+
+		request.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
 
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, route.Path)
+		require.JSONEq(t, `{"result":{}}`, string(body))
+	}
 }
 
 func printResult(v interface{}) string {
@@ -0,0 +1,57 @@
+package validator
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Binder binds types the caller does not own, e.g. decimal, UUID or big.Int, from a single text value
+type Binder interface {
+	// Bind parse the parameter text (JSON strings are unescaped), returns T or *T
+	Bind(text string, metadata *Metadata) (interface{}, error)
+	// Format returns the parameter text sent by client, value is *T
+	Format(value interface{}, metadata *Metadata) (string, error)
+}
+
+var binders sync.Map // reflect.Type -> Binder
+
+// Register register binder of paramT (pointer types are registered as their element type),
+// parameters are bound in the following order of precedence:
+//
+//  1. registered binders
+//  2. types implementing restrpc.Validator, constructed with reflect.New
+//  3. time.Time, time.Duration and url.URL
+//  4. encoding.TextUnmarshaler
+//  5. json.Unmarshaler
+//  6. the type kind: numbers, bool, string, struct, slice, array and map
+//
+// the client encodes parameters with the same precedence, using Binder.Format, restrpc.Writer,
+// encoding.TextMarshaler and json.Marshaler for 1, 2, 4 and 5.
+// A binder makes its type a single parameter, the cached struct fields are resolved again
+func Register(paramT reflect.Type, binder Binder) {
+	for paramT.Kind() == reflect.Ptr {
+		paramT = paramT.Elem()
+	}
+
+	binders.Store(paramT, binder)
+
+	clearCache(&fieldsCache)
+	clearCache(&recursiveCache)
+}
+
+func clearCache(cache *sync.Map) {
+	cache.Range(func(key, value interface{}) bool {
+		cache.Delete(key)
+		return true
+	})
+}
+
+func lookupBinder(paramT reflect.Type) (Binder, bool) {
+	binder, ok := binders.Load(paramT)
+
+	if !ok {
+		return nil, false
+	}
+
+	return binder.(Binder), true
+}
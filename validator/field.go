@@ -0,0 +1,280 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/dynamicgo/xerrors"
+)
+
+// Field struct field bound by Validate
+type Field struct {
+	Name     string        // parameter name
+	Index    []int         // index sequence for reflect.Value.FieldByIndex
+	Type     reflect.Type  // field type
+	Metadata *Metadata     // rest tag metadata
+	defaults []string      // default values expanded when the type is first used
+	literal  reflect.Value // literal default bound to Type for IsDefault, invalid if none
+}
+
+// IsDefault check whether value equals to the literal field default like the IsDefault function,
+// the default is bound once per type
+func (field *Field) IsDefault(value reflect.Value) bool {
+	return equalDefault(value, field.literal)
+}
+
+// isScalar check whether valueT without pointers is a text, number, bool, string or a slice of them
+func isScalar(valueT reflect.Type) bool {
+	for valueT.Kind() == reflect.Ptr {
+		valueT = valueT.Elem()
+	}
+
+	if IsText(valueT) {
+		return true
+	}
+
+	switch valueT.Kind() {
+	case reflect.Slice, reflect.Array:
+		elemT := valueT.Elem()
+
+		return elemT.Kind() != reflect.Slice && elemT.Kind() != reflect.Array && isScalar(elemT)
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return false
+	default:
+		return true
+	}
+}
+
+// parseDefault expand the field default values and bind the literal default
+func (field *Field) parseDefault() {
+	if field.Metadata.Default == nil {
+		return
+	}
+
+	field.defaults = field.Metadata.DefaultValues()
+
+	// composite defaults may refer to the type being resolved
+	if strings.Contains(*field.Metadata.Default, "$") || !isScalar(field.Type) {
+		return
+	}
+
+	field.literal = literalDefault(field.Type, field.Metadata, field.defaults)
+}
+
+type fieldsResult struct {
+	fields []Field
+	err    error
+}
+
+var fieldsCache sync.Map // reflect.Type -> *fieldsResult
+
+// Fields returns the parameter fields of struct type paramT: unexported, "-" and PresenceSet fields are skipped,
+// anonymous embedded structs without rest name are flattened into the parent like encoding/json,
+// the shallowest field wins and fields with the same name at the same depth are a collision error
+func Fields(paramT reflect.Type) ([]Field, error) {
+	if cached, ok := fieldsCache.Load(paramT); ok {
+		result := cached.(*fieldsResult)
+		return result.fields, result.err
+	}
+
+	fields, err := resolveFields(paramT)
+
+	fieldsCache.Store(paramT, &fieldsResult{fields: fields, err: err})
+
+	return fields, err
+}
+
+type candidate struct {
+	Field
+	depth int
+}
+
+func collectFields(paramT reflect.Type, index []int, visited map[reflect.Type]bool, candidates []candidate) ([]candidate, error) {
+	visited[paramT] = true
+	defer delete(visited, paramT)
+
+	for i := 0; i < paramT.NumField(); i++ {
+		field := paramT.Field(i)
+
+		metadata, err := ParseTag(field.Tag.Get(MetadataTag))
+
+		if err != nil {
+			return nil, xerrors.Wrapf(err, "%s field %s", paramT, field.Name)
+		}
+
+		// skipped fields and the PresenceSet are not parameters
+		if metadata.Skipped || field.Type == presenceSetType {
+			continue
+		}
+
+		fieldIndex := make([]int, len(index), len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex = append(fieldIndex, i)
+
+		if field.Anonymous {
+			embeddedT := field.Type
+
+			if embeddedT.Kind() == reflect.Ptr {
+				embeddedT = embeddedT.Elem()
+			}
+
+			flatten := embeddedT.Kind() == reflect.Struct && !IsText(embeddedT) && metadata.Name == ""
+
+			// unexported embedded pointers can not be allocated
+			if flatten && !(field.PkgPath != "" && field.Type.Kind() == reflect.Ptr) {
+				if !visited[embeddedT] {
+					if candidates, err = collectFields(embeddedT, fieldIndex, visited, candidates); err != nil {
+						return nil, err
+					}
+				}
+
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.ToLower(field.Name)
+
+		if metadata.Name != "" {
+			name = metadata.Name
+		}
+
+		candidates = append(candidates, candidate{
+			Field: Field{
+				Name:     name,
+				Index:    fieldIndex,
+				Type:     field.Type,
+				Metadata: metadata,
+			},
+			depth: len(fieldIndex),
+		})
+	}
+
+	return candidates, nil
+}
+
+func resolveFields(paramT reflect.Type) ([]Field, error) {
+	candidates, err := collectFields(paramT, nil, make(map[reflect.Type]bool), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the shallowest depth of every name, deeper fields with the same name are hidden
+	depths := make(map[string]int)
+
+	for _, c := range candidates {
+		if depth, ok := depths[c.Name]; !ok || c.depth < depth {
+			depths[c.Name] = c.depth
+		}
+	}
+
+	dominant := make(map[string]candidate)
+
+	var fields []Field
+
+	for _, c := range candidates {
+		if c.depth != depths[c.Name] {
+			continue
+		}
+
+		if current, ok := dominant[c.Name]; ok {
+			return nil, xerrors.Wrapf(ErrCollision, "%s fields %s and %s are both named %s",
+				paramT, fieldPath(paramT, current.Index), fieldPath(paramT, c.Index), c.Name)
+		}
+
+		dominant[c.Name] = c
+
+		c.Field.parseDefault()
+
+		fields = append(fields, c.Field)
+	}
+
+	return fields, nil
+}
+
+func fieldPath(paramT reflect.Type, index []int) string {
+	var names []string
+
+	for _, i := range index {
+		if paramT.Kind() == reflect.Ptr {
+			paramT = paramT.Elem()
+		}
+
+		field := paramT.Field(i)
+		names = append(names, field.Name)
+		paramT = field.Type
+	}
+
+	return strings.Join(names, ".")
+}
+
+// Check check the parameter fields of paramT and its nested struct types, e.g. name collisions,
+// the server checks service parameters at registration time
+func Check(paramT reflect.Type) error {
+	return check(paramT, make(map[reflect.Type]bool))
+}
+
+func check(paramT reflect.Type, checked map[reflect.Type]bool) error {
+	for kind := paramT.Kind(); kind == reflect.Ptr || kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map; kind = paramT.Kind() {
+		paramT = paramT.Elem()
+	}
+
+	if paramT.Kind() != reflect.Struct || IsText(paramT) || checked[paramT] {
+		return nil
+	}
+
+	checked[paramT] = true
+
+	fields, err := Fields(paramT)
+
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if err := check(field.Type, checked); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fieldByIndex returns the struct field, allocating nil embedded pointers
+func fieldByIndex(value reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+
+			value = value.Elem()
+		}
+
+		value = value.Field(x)
+	}
+
+	return value
+}
+
+// FieldValue returns the struct field value, ok is false if an embedded pointer is nil
+func FieldValue(value reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+
+			value = value.Elem()
+		}
+
+		value = value.Field(x)
+	}
+
+	return value, true
+}
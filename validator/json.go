@@ -0,0 +1,534 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/xerrors"
+)
+
+// maxJSONDepth bounds the parser recursion and so its goroutine stack whatever the JSONLimits,
+// the server Limits default to far less
+const maxJSONDepth = 1000
+
+// JSONLimits json shape limits checked while parsing, zero disables the limit
+type JSONLimits struct {
+	MaxDepth       int // max nesting depth, never more than 1000
+	MaxArrayLength int // max array elements
+	MaxMapKeys     int // max object keys
+}
+
+// Kind json value type
+type Kind int
+
+// Json value types
+const (
+	KindNull Kind = iota
+	KindBool
+	KindNumber
+	KindString
+	KindArray
+	KindObject
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case KindNull:
+		return "null"
+	case KindBool:
+		return "boolean"
+	case KindNumber:
+		return "number"
+	case KindString:
+		return "string"
+	case KindArray:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonNode parsed json value
+type jsonNode struct {
+	kind     Kind
+	text     string // scalar text, strings are unescaped
+	start    int    // value byte offset
+	end      int
+	elements []*jsonNode
+	keys     []string // object keys in input order
+	fields   map[string]*jsonNode
+}
+
+type jsonParser struct {
+	data   []byte
+	pos    int
+	depth  int
+	limits JSONLimits
+}
+
+func (parser *jsonParser) skipSpace() {
+	for parser.pos < len(parser.data) {
+		switch parser.data[parser.pos] {
+		case ' ', '\t', '\n', '\r':
+			parser.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (parser *jsonParser) syntaxError(expect string) error {
+	if parser.pos >= len(parser.data) {
+		return xerrors.Wrapf(ErrJSONSyntax, "unexpected end of input at offset %d, expect %s", parser.pos, expect)
+	}
+
+	return xerrors.Wrapf(ErrJSONSyntax, "invalid character %q at offset %d, expect %s", parser.data[parser.pos], parser.pos, expect)
+}
+
+func (parser *jsonParser) parseValue() (*jsonNode, error) {
+	parser.skipSpace()
+
+	if parser.pos >= len(parser.data) {
+		return nil, parser.syntaxError("value")
+	}
+
+	switch c := parser.data[parser.pos]; {
+	case c == '{':
+		return parser.parseObject()
+	case c == '[':
+		return parser.parseArray()
+	case c == '"':
+		start := parser.pos
+
+		text, err := parser.parseString()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &jsonNode{kind: KindString, text: text, start: start, end: parser.pos}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return parser.parseNumber()
+	case c == 't':
+		return parser.parseLiteral("true", KindBool)
+	case c == 'f':
+		return parser.parseLiteral("false", KindBool)
+	case c == 'n':
+		return parser.parseLiteral("null", KindNull)
+	default:
+		return nil, parser.syntaxError("value")
+	}
+}
+
+func (parser *jsonParser) parseLiteral(literal string, kind Kind) (*jsonNode, error) {
+	start := parser.pos
+
+	if !bytes.HasPrefix(parser.data[start:], []byte(literal)) {
+		return nil, xerrors.Wrapf(ErrJSONSyntax, "invalid literal at offset %d, expect %s", start, literal)
+	}
+
+	parser.pos += len(literal)
+
+	return &jsonNode{kind: kind, text: literal, start: start, end: parser.pos}, nil
+}
+
+func (parser *jsonParser) digits() int {
+	start := parser.pos
+
+	for parser.pos < len(parser.data) && parser.data[parser.pos] >= '0' && parser.data[parser.pos] <= '9' {
+		parser.pos++
+	}
+
+	return parser.pos - start
+}
+
+func (parser *jsonParser) parseNumber() (*jsonNode, error) {
+	start := parser.pos
+
+	if parser.data[parser.pos] == '-' {
+		parser.pos++
+	}
+
+	if parser.pos < len(parser.data) && parser.data[parser.pos] == '0' {
+		parser.pos++
+	} else if parser.digits() == 0 {
+		return nil, parser.syntaxError("digit")
+	}
+
+	if parser.pos < len(parser.data) && parser.data[parser.pos] == '.' {
+		parser.pos++
+
+		if parser.digits() == 0 {
+			return nil, parser.syntaxError("digit")
+		}
+	}
+
+	if parser.pos < len(parser.data) && (parser.data[parser.pos] == 'e' || parser.data[parser.pos] == 'E') {
+		parser.pos++
+
+		if parser.pos < len(parser.data) && (parser.data[parser.pos] == '+' || parser.data[parser.pos] == '-') {
+			parser.pos++
+		}
+
+		if parser.digits() == 0 {
+			return nil, parser.syntaxError("digit")
+		}
+	}
+
+	return &jsonNode{kind: KindNumber, text: string(parser.data[start:parser.pos]), start: start, end: parser.pos}, nil
+}
+
+// parseString parse the string at pos, escaped strings are rare and decoded by encoding/json
+func (parser *jsonParser) parseString() (string, error) {
+	start := parser.pos
+
+	parser.pos++
+
+	escaped := false
+
+	for parser.pos < len(parser.data) {
+		c := parser.data[parser.pos]
+
+		switch {
+		case c == '"':
+			parser.pos++
+
+			// escapes are ascii, encoding/json would replace invalid bytes with U+FFFD
+			if !utf8.Valid(parser.data[start+1 : parser.pos-1]) {
+				return "", xerrors.Wrapf(ErrJSONSyntax, "invalid utf-8 string at offset %d", start)
+			}
+
+			if !escaped {
+				return string(parser.data[start+1 : parser.pos-1]), nil
+			}
+
+			var text string
+
+			if err := json.Unmarshal(parser.data[start:parser.pos], &text); err != nil {
+				return "", xerrors.Wrapf(ErrJSONSyntax, "invalid string at offset %d: %s", start, err)
+			}
+
+			return text, nil
+		case c == '\\':
+			escaped = true
+			parser.pos += 2
+		case c < 0x20:
+			return "", parser.syntaxError("string character")
+		default:
+			parser.pos++
+		}
+	}
+
+	parser.pos = len(parser.data)
+
+	return "", parser.syntaxError(`'"'`)
+}
+
+func (parser *jsonParser) enter() error {
+	parser.depth++
+
+	maxDepth := maxJSONDepth
+
+	if parser.limits.MaxDepth > 0 && parser.limits.MaxDepth < maxDepth {
+		maxDepth = parser.limits.MaxDepth
+	}
+
+	if parser.depth > maxDepth {
+		return xerrors.Wrapf(ErrJSONDepth, "json nesting exceeds depth %d at offset %d", maxDepth, parser.pos)
+	}
+
+	return nil
+}
+
+func (parser *jsonParser) parseArray() (*jsonNode, error) {
+	if err := parser.enter(); err != nil {
+		return nil, err
+	}
+
+	defer func() { parser.depth-- }()
+
+	node := &jsonNode{kind: KindArray, start: parser.pos, elements: []*jsonNode{}}
+
+	parser.pos++
+	parser.skipSpace()
+
+	if parser.pos < len(parser.data) && parser.data[parser.pos] == ']' {
+		parser.pos++
+		node.end = parser.pos
+
+		return node, nil
+	}
+
+	for {
+		if parser.limits.MaxArrayLength > 0 && len(node.elements) >= parser.limits.MaxArrayLength {
+			return nil, xerrors.Wrapf(ErrJSONLength, "json array at offset %d exceeds %d elements", node.start, parser.limits.MaxArrayLength)
+		}
+
+		element, err := parser.parseValue()
+
+		if err != nil {
+			return nil, err
+		}
+
+		node.elements = append(node.elements, element)
+
+		parser.skipSpace()
+
+		if parser.pos >= len(parser.data) {
+			return nil, parser.syntaxError("',' or ']'")
+		}
+
+		switch parser.data[parser.pos] {
+		case ',':
+			parser.pos++
+		case ']':
+			parser.pos++
+			node.end = parser.pos
+
+			return node, nil
+		default:
+			return nil, parser.syntaxError("',' or ']'")
+		}
+	}
+}
+
+func (parser *jsonParser) parseObject() (*jsonNode, error) {
+	if err := parser.enter(); err != nil {
+		return nil, err
+	}
+
+	defer func() { parser.depth-- }()
+
+	node := &jsonNode{kind: KindObject, start: parser.pos, fields: make(map[string]*jsonNode)}
+
+	parser.pos++
+	parser.skipSpace()
+
+	if parser.pos < len(parser.data) && parser.data[parser.pos] == '}' {
+		parser.pos++
+		node.end = parser.pos
+
+		return node, nil
+	}
+
+	for count := 1; ; count++ {
+		parser.skipSpace()
+
+		if parser.pos >= len(parser.data) || parser.data[parser.pos] != '"' {
+			return nil, parser.syntaxError("object key")
+		}
+
+		// duplicated keys count too
+		if parser.limits.MaxMapKeys > 0 && count > parser.limits.MaxMapKeys {
+			return nil, xerrors.Wrapf(ErrJSONKeys, "json object at offset %d exceeds %d keys", node.start, parser.limits.MaxMapKeys)
+		}
+
+		key, err := parser.parseString()
+
+		if err != nil {
+			return nil, err
+		}
+
+		parser.skipSpace()
+
+		if parser.pos >= len(parser.data) || parser.data[parser.pos] != ':' {
+			return nil, parser.syntaxError("':'")
+		}
+
+		parser.pos++
+
+		value, err := parser.parseValue()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// duplicated keys: the last value wins like encoding/json
+		if _, ok := node.fields[key]; !ok {
+			node.keys = append(node.keys, key)
+		}
+
+		node.fields[key] = value
+
+		parser.skipSpace()
+
+		if parser.pos >= len(parser.data) {
+			return nil, parser.syntaxError("',' or '}'")
+		}
+
+		switch parser.data[parser.pos] {
+		case ',':
+			parser.pos++
+		case '}':
+			parser.pos++
+			node.end = parser.pos
+
+			return node, nil
+		default:
+			return nil, parser.syntaxError("',' or '}'")
+		}
+	}
+}
+
+// parseJSONTree parse the whole content into a tree of jsonNode checking the limits,
+// the tree holds every value of the document until the reader is released
+func parseJSONTree(content []byte, limits JSONLimits) (*jsonNode, error) {
+	parser := &jsonParser{data: content, limits: limits}
+
+	node, err := parser.parseValue()
+
+	if err != nil {
+		return nil, err
+	}
+
+	parser.skipSpace()
+
+	if parser.pos < len(parser.data) {
+		return nil, parser.syntaxError("end of input")
+	}
+
+	return node, nil
+}
+
+type jsonReader struct {
+	path []string
+	node *jsonNode // nil if missing
+	data []byte
+}
+
+// NewJSONReader create reader of json content, the content is parsed into a tree preserving
+// the value types, strings are unescaped and numbers keep their literal
+func NewJSONReader(content []byte) (restrpc.Reader, error) {
+	return NewJSONReaderWithLimits(content, JSONLimits{})
+}
+
+// NewJSONReaderWithLimits create reader of json content rejecting content beyond the limits
+// with ErrJSONDepth, ErrJSONLength or ErrJSONKeys
+func NewJSONReaderWithLimits(content []byte, limits JSONLimits) (restrpc.Reader, error) {
+	node, err := parseJSONTree(content, limits)
+
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "parse input json content error")
+	}
+
+	return &jsonReader{
+		node: node,
+		data: content,
+	}, nil
+}
+
+func (reader *jsonReader) Search(key string) ([]string, error) {
+	return reader.Reader(key).Get()
+}
+
+// Get returns the scalar text or the raw json of arrays and objects, missing and null values
+// yield nothing, so that defaults and required checks apply
+func (reader *jsonReader) Get() ([]string, error) {
+	node := reader.node
+
+	if node == nil || node.kind == KindNull {
+		return nil, nil
+	}
+
+	if node.kind == KindArray || node.kind == KindObject {
+		return []string{string(reader.data[node.start:node.end])}, nil
+	}
+
+	return []string{node.text}, nil
+}
+
+func (reader *jsonReader) Range(f func(key string, reader restrpc.Reader) error) error {
+	node := reader.node
+
+	if node == nil || node.kind == KindNull {
+		return nil
+	}
+
+	if node.kind != KindObject {
+		return xerrors.Wrapf(ErrInvalidType, "expect object at path %s offset %d, got %s", reader.Path(), node.start, node.kind)
+	}
+
+	for _, key := range node.keys {
+		if err := f(key, reader.Reader(key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (reader *jsonReader) Path() string {
+	return strings.Join(reader.path, ".")
+}
+
+func (reader *jsonReader) Reader(key string) restrpc.Reader {
+	var child *jsonNode
+
+	if reader.node != nil && reader.node.kind == KindObject {
+		child = reader.node.fields[key]
+	}
+
+	return &jsonReader{
+		path: childPath(reader.path, key),
+		node: child,
+		data: reader.data,
+	}
+}
+
+func (reader *jsonReader) Elements() ([]restrpc.Reader, error) {
+	node := reader.node
+
+	if node == nil || node.kind == KindNull {
+		return nil, nil
+	}
+
+	if node.kind != KindArray {
+		return nil, xerrors.Wrapf(ErrInvalidType, "expect array at path %s offset %d, got %s", reader.Path(), node.start, node.kind)
+	}
+
+	elements := make([]restrpc.Reader, 0, len(node.elements))
+
+	for i, element := range node.elements {
+		elements = append(elements, &jsonReader{
+			path: childPath(reader.path, strconv.Itoa(i)),
+			node: element,
+			data: reader.data,
+		})
+	}
+
+	return elements, nil
+}
+
+// Presence .
+func (reader *jsonReader) Presence() Presence {
+	switch {
+	case reader.node == nil:
+		return Missing
+	case reader.node.kind == KindNull:
+		return Null
+	default:
+		return Present
+	}
+}
+
+// Kind returns the json type and byte offset of the value, ok is false if missing
+func (reader *jsonReader) Kind() (kind Kind, offset int, ok bool) {
+	if reader.node == nil {
+		return KindNull, 0, false
+	}
+
+	return reader.node.kind, reader.node.start, true
+}
+
+// Raw returns the raw json value
+func (reader *jsonReader) Raw() []byte {
+	if reader.node == nil {
+		return nil
+	}
+
+	return reader.data[reader.node.start:reader.node.end]
+}
@@ -0,0 +1,320 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Jeffail/gabs"
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/xerrors"
+	"github.com/stretchr/testify/require"
+)
+
+type testJSON struct {
+	Name    string
+	Escaped string
+	Empty   string
+	Null    *string
+	Missing *string
+	Flag    bool
+	Count   int64
+	Tags    []string
+	None    []string
+	Items   []testItem
+	Labels  map[string]string
+	Scores  map[string]int
+}
+
+func TestJSONReader(t *testing.T) {
+	reader, err := NewJSONReader([]byte(`{
+		"name": "hello",
+		"escaped": "a\"b\\cé\n",
+		"empty": "",
+		"null": null,
+		"flag": true,
+		"count": 9007199254740993,
+		"tags": ["x", "y"],
+		"none": [],
+		"items": [{"name": "i", "count": 1}],
+		"labels": {"a": "1", "b": "2"},
+		"scores": {"c": 3}
+	}`))
+
+	require.NoError(t, err)
+
+	values, err := Validate(reader, reflect.TypeOf(&testJSON{}))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testJSON)
+
+	require.Equal(t, "hello", result.Name)
+	require.Equal(t, "a\"b\\cé\n", result.Escaped)
+	require.Equal(t, "", result.Empty)
+	require.Nil(t, result.Null)
+	require.Nil(t, result.Missing)
+	require.True(t, result.Flag)
+	require.Equal(t, int64(9007199254740993), result.Count)
+	require.Equal(t, []string{"x", "y"}, result.Tags)
+	require.NotNil(t, result.None)
+	require.Len(t, result.None, 0)
+	require.Equal(t, []testItem{{Name: "i", Count: 1}}, result.Items)
+	require.Equal(t, map[string]string{"a": "1", "b": "2"}, result.Labels)
+	require.Equal(t, map[string]int{"c": 3}, result.Scores)
+
+	require.Equal(t, Present, reader.Reader("empty").(PresenceReader).Presence())
+	require.Equal(t, Null, reader.Reader("null").(PresenceReader).Presence())
+	require.Equal(t, Missing, reader.Reader("missing").(PresenceReader).Presence())
+	require.Equal(t, Missing, reader.Reader("name").Reader("x").(PresenceReader).Presence())
+	require.Equal(t, `[{"name": "i", "count": 1}]`, string(reader.Reader("items").(RawReader).Raw()))
+}
+
+func TestJSONReaderErrors(t *testing.T) {
+	for content, offset := range map[string]string{
+		`{"a":1,}`:            "offset 7",
+		`{"a" 1}`:             "offset 5",
+		`[1,2`:                "offset 4",
+		`{"a":tru}`:           "offset 5",
+		`{"a":01}`:            "offset 6",
+		`{"a":-}`:             "offset 6",
+		`{"a":"b`:             "offset 7",
+		"{\"a\":\"\x01\"}":    "offset 6",
+		`{"a":1} x`:           "offset 8",
+		``:                    "offset 0",
+		`{"a":"\u00"}`:        "offset 5",
+		`{"a":1.}`:            "offset 7",
+		`{"a":[1,{"b":]}]}`:   "offset 13",
+		"{\"a\":\"\xff\"}":    "offset 5",
+		"{\"a\":\"\\n\xff\"}": "offset 5",
+	} {
+		_, err := NewJSONReader([]byte(content))
+
+		require.True(t, errors.Is(err, ErrJSONSyntax), "%s: %v", content, err)
+		require.Contains(t, err.Error(), offset, content)
+	}
+
+	// valid utf-8 is kept with and without escapes
+	for content, expected := range map[string]string{
+		`{"a":"é"}`:   "é",
+		`{"a":"é\n"}`: "é\n",
+	} {
+		reader, err := NewJSONReader([]byte(content))
+		require.NoError(t, err, content)
+
+		values, err := reader.Reader("a").Get()
+		require.NoError(t, err)
+		require.Equal(t, []string{expected}, values)
+	}
+
+	_, err := NewJSONReader([]byte(strings.Repeat("[", maxJSONDepth+1)))
+	require.True(t, errors.Is(err, ErrJSONDepth), "%v", err)
+
+	reader, err := NewJSONReader([]byte(`{"tags": "x", "labels": [1]}`))
+	require.NoError(t, err)
+
+	_, err = Validate(reader, reflect.TypeOf(&testJSON{}))
+	require.True(t, errors.Is(err, ErrInvalidType), "%v", err)
+	require.Contains(t, err.Error(), "offset 9")
+}
+
+func TestJSONKindMismatch(t *testing.T) {
+	// field errors name the struct field, the detail has the element path and offset
+	for content, expect := range map[string][2]string{
+		`{"name":{"a":1}}`:       {"name", "expect string at path name offset 8, got object"},
+		`{"name":1}`:             {"name", "expect string at path name offset 8, got number"},
+		`{"count":{"a":1}}`:      {"count", "expect number at path count offset 9, got object"},
+		`{"count":true}`:         {"count", "expect number at path count offset 9, got boolean"},
+		`{"flag":1}`:             {"flag", "expect boolean at path flag offset 8, got number"},
+		`{"flag":[true]}`:        {"flag", "expect boolean at path flag offset 8, got array"},
+		`{"tags":["a",[1]]}`:     {"tags", "expect string at path tags.1 offset 13, got array"},
+		`{"scores":{"c":[3]}}`:   {"scores", "expect number at path scores.c offset 15, got array"},
+		`{"labels":{"a":false}}`: {"labels", "expect string at path labels.a offset 15, got boolean"},
+	} {
+		reader, err := NewJSONReader([]byte(content))
+		require.NoError(t, err)
+
+		_, err = Validate(reader, reflect.TypeOf(&testJSON{}))
+
+		var fieldErr *restrpc.FieldError
+
+		require.True(t, errors.As(err, &fieldErr), "%s: %v", content, err)
+		require.True(t, errors.Is(err, ErrInvalidType), "%s: %v", content, err)
+		require.Equal(t, expect[0], fieldErr.Field, content)
+		require.Contains(t, fieldErr.Detail, expect[1], content)
+	}
+
+	// quoted numbers and booleans are parsed like query values
+	reader, err := NewJSONReader([]byte(`{"count":"123","flag":"true","scores":{"c":"3"}}`))
+	require.NoError(t, err)
+
+	values, err := Validate(reader, reflect.TypeOf(&testJSON{}))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testJSON)
+
+	require.Equal(t, int64(123), result.Count)
+	require.True(t, result.Flag)
+	require.Equal(t, map[string]int{"c": 3}, result.Scores)
+
+	reader, err = NewJSONReader([]byte(`{"count":"12a"}`))
+	require.NoError(t, err)
+
+	_, err = Validate(reader, reflect.TypeOf(&testJSON{}))
+	require.True(t, errors.Is(err, ErrNumber), "%v", err)
+
+	// null and missing values are not typed
+	reader, err = NewJSONReader([]byte(`{"name":null,"count":null,"flag":null}`))
+	require.NoError(t, err)
+
+	_, err = Validate(reader, reflect.TypeOf(&testJSON{}))
+	require.NoError(t, err)
+
+	kind, offset, ok := reader.Reader("count").(KindReader).Kind()
+	require.True(t, ok)
+	require.Equal(t, KindNull, kind)
+	require.Equal(t, 21, offset)
+
+	_, _, ok = reader.Reader("missing").(KindReader).Kind()
+	require.False(t, ok)
+}
+
+// benchmarkParam fields readable by both the current and the baseline json reader
+type benchmarkParam struct {
+	Name    string
+	Escaped string
+	Empty   string
+	Flag    bool
+	Count   int64
+	Tags    []string
+	Items   []testItem
+	Labels  map[string]string
+}
+
+var benchmarkJSON = []byte(`{
+	"name": "hello",
+	"escaped": "wor\"ld \u00e9\n",
+	"empty": "",
+	"flag": true,
+	"count": 123456789,
+	"tags": ["a", "b", "c", "d"],
+	"items": [{"name": "x", "count": 1}, {"name": "y", "count": 2}, {"name": "z", "count": 3}],
+	"labels": {"a": "1", "b": "2", "c": "3"}
+}`)
+
+func BenchmarkJSONReader(b *testing.B) {
+	paramT := reflect.TypeOf(&benchmarkParam{})
+
+	for i := 0; i < b.N; i++ {
+		reader, err := NewJSONReader(benchmarkJSON)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := Validate(reader, paramT); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// baselineJSONReader the gabs backed json reader before the json tree parser, copied
+// verbatim but for the names, kept for benchmark comparison
+type baselineJSONReader struct {
+	path      []string
+	container *gabs.Container
+}
+
+func newBaselineJSONReader(content []byte) (restrpc.Reader, error) {
+	container, err := gabs.ParseJSON(content)
+
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "parse input json content error: %s", string(content))
+	}
+
+	return &baselineJSONReader{
+		container: container,
+	}, nil
+}
+
+func (reader *baselineJSONReader) Search(key string) ([]string, error) {
+
+	path := strings.Join(append(reader.path, key), ".")
+
+	value := reader.container.Path(path).String()
+
+	return []string{value}, nil
+}
+func (reader *baselineJSONReader) Get() ([]string, error) {
+
+	path := strings.Join(reader.path, ".")
+
+	value := reader.container.Path(path).String()
+
+	return []string{value}, nil
+}
+func (reader *baselineJSONReader) Range(f func(key string, reader restrpc.Reader) error) error {
+
+	path := strings.Join(reader.path, ".")
+
+	children, err := reader.container.Path(path).ChildrenMap()
+
+	if err != nil {
+		return xerrors.Wrapf(err, "get path %s children map error", path)
+	}
+
+	for key := range children {
+		err := f(key, &baselineJSONReader{
+			path:      append(reader.path, key),
+			container: reader.container,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+func (reader *baselineJSONReader) Path() string {
+	return strings.Join(reader.path, ".")
+}
+
+func (reader *baselineJSONReader) Reader(key string) restrpc.Reader {
+	return &baselineJSONReader{
+		path:      append(reader.path, key),
+		container: reader.container,
+	}
+}
+
+func BenchmarkBaselineJSONReader(b *testing.B) {
+	paramT := reflect.TypeOf(&benchmarkParam{})
+
+	for i := 0; i < b.N; i++ {
+		reader, err := newBaselineJSONReader(benchmarkJSON)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := Validate(reader, paramT); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := parseJSONTree(benchmarkJSON, JSONLimits{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGabsParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := gabs.ParseJSON(benchmarkJSON); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
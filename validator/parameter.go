@@ -0,0 +1,118 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/dynamicgo/restrpc"
+)
+
+// Parameter parameter description used by API documentation and introspection
+type Parameter struct {
+	Name     string  `json:"name"` // parameter path, nested fields are joined with '.'
+	Type     string  `json:"type"` // go type
+	Required bool    `json:"required,omitempty"`
+	Default  *string `json:"default,omitempty"` // default value as declared in the rest tag
+}
+
+// Parameters describe the parameters bound by Validate for paramT
+func Parameters(paramT reflect.Type) []Parameter {
+	return describe(paramT, "", make(map[reflect.Type]bool))
+}
+
+// describe returns the parameters of struct paramT, visiting holds the struct types being described
+// so that a self referencing field is described as a single parameter
+func describe(paramT reflect.Type, prefix string, visiting map[reflect.Type]bool) []Parameter {
+	for paramT.Kind() == reflect.Ptr {
+		paramT = paramT.Elem()
+	}
+
+	if paramT.Kind() != reflect.Struct || IsText(paramT) || visiting[paramT] {
+		return nil
+	}
+
+	visiting[paramT] = true
+	defer delete(visiting, paramT)
+
+	fields, err := Fields(paramT)
+
+	if err != nil {
+		return nil
+	}
+
+	var parameters []Parameter
+
+	for _, field := range fields {
+		name := field.Name
+
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		if nested := describe(field.Type, name, visiting); nested != nil {
+			parameters = append(parameters, nested...)
+			continue
+		}
+
+		parameters = append(parameters, Parameter{
+			Name:     name,
+			Type:     field.Type.String(),
+			Required: field.Metadata.Required,
+			Default:  field.Metadata.Default,
+		})
+	}
+
+	return parameters
+}
+
+var recursiveCache sync.Map // reflect.Type -> bool
+
+// isRecursive check whether paramT is a pointer to a struct that refers back to itself through
+// nested structs or pointers, e.g. Next *Node of Node, binding a missing one would never end
+func isRecursive(paramT reflect.Type) bool {
+	if paramT.Kind() != reflect.Ptr || paramT.Elem().Kind() != reflect.Struct {
+		return false
+	}
+
+	if cached, ok := recursiveCache.Load(paramT); ok {
+		return cached.(bool)
+	}
+
+	recursive := reaches(paramT.Elem(), paramT.Elem(), make(map[reflect.Type]bool))
+
+	recursiveCache.Store(paramT, recursive)
+
+	return recursive
+}
+
+func reaches(paramT reflect.Type, target reflect.Type, visited map[reflect.Type]bool) bool {
+	visited[paramT] = true
+
+	for i := 0; i < paramT.NumField(); i++ {
+		fieldT := paramT.Field(i).Type
+
+		for fieldT.Kind() == reflect.Ptr {
+			fieldT = fieldT.Elem()
+		}
+
+		if fieldT.Kind() != reflect.Struct || IsText(fieldT) {
+			continue
+		}
+
+		if fieldT == target || (!visited[fieldT] && reaches(fieldT, target, visited)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var errNotEmpty = errors.New("not empty")
+
+// isEmpty check whether reader has no child parameters, e.g. a missing nested struct
+func isEmpty(reader restrpc.Reader) bool {
+	return reader.Range(func(key string, reader restrpc.Reader) error {
+		return errNotEmpty
+	}) == nil
+}
@@ -0,0 +1,69 @@
+package validator
+
+import (
+	"reflect"
+
+	"github.com/dynamicgo/restrpc"
+)
+
+// PresenceSet records which parameters of the struct declaring it were sent, so that PATCH services
+// can apply only the provided fields. Validate fills the exported PresenceSet field of every bound struct,
+// the keys are the parameter names of the struct fields, missing parameters are not recorded:
+//
+//	type PatchUser struct {
+//		ID       string `rest:"required"`
+//		Name     *string
+//		Age      int
+//		Presence validator.PresenceSet
+//	}
+//
+// a json null is not bound, the field keeps its zero value, even with a default, and is reported as Null. The client
+// sends fields marked Present even if they are zero or default and sends null for fields marked Null
+type PresenceSet map[string]Presence
+
+var presenceSetType = reflect.TypeOf(PresenceSet(nil))
+
+// Presence returns the presence of parameter name
+func (set PresenceSet) Presence(name string) Presence {
+	return set[name]
+}
+
+// Has check whether parameter name was sent, including json null
+func (set PresenceSet) Has(name string) bool {
+	return set[name] != Missing
+}
+
+// IsNull check whether parameter name was sent as json null
+func (set PresenceSet) IsNull(name string) bool {
+	return set[name] == Null
+}
+
+// PresenceIndex returns the index of the PresenceSet field of struct type paramT
+func PresenceIndex(paramT reflect.Type) ([]int, bool) {
+	for paramT.Kind() == reflect.Ptr {
+		paramT = paramT.Elem()
+	}
+
+	if paramT.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for i := 0; i < paramT.NumField(); i++ {
+		field := paramT.Field(i)
+
+		if field.Type == presenceSetType && field.PkgPath == "" {
+			return field.Index, true
+		}
+	}
+
+	return nil, false
+}
+
+// presence returns the reader presence, known is false for readers without PresenceReader
+func presence(reader restrpc.Reader) (state Presence, known bool) {
+	if presenceReader, ok := reader.(PresenceReader); ok {
+		return presenceReader.Presence(), true
+	}
+
+	return Missing, false
+}
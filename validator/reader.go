@@ -2,12 +2,11 @@ package validator
 
 import (
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/dynamicgo/restrpc"
-	"github.com/dynamicgo/xerrors"
-
-	"github.com/Jeffail/gabs"
 )
 
 type queryReader struct {
@@ -39,20 +38,37 @@ func (reader *queryReader) Get() ([]string, error) {
 	return values, nil
 }
 
+// Range calls f with the direct child keys of reader path, e.g. a for m.a=1 and m.a.b=2
 func (reader *queryReader) Range(f func(key string, reader restrpc.Reader) error) error {
 
-	path := strings.Join(reader.path, ".")
+	prefix := reader.Path() + "."
+
+	if len(reader.path) == 0 {
+		prefix = ""
+	}
+
+	var keys []string
+
+	visited := make(map[string]bool)
 
 	for key := range reader.values {
-		if strings.HasPrefix(key, path) {
-			err := f(key, &queryReader{
-				path:   strings.Split(key, "."),
-				values: reader.values,
-			})
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
 
-			if err != nil {
-				return err
-			}
+		child := strings.SplitN(strings.TrimPrefix(key, prefix), ".", 2)[0]
+
+		if !visited[child] {
+			visited[child] = true
+			keys = append(keys, child)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := f(key, reader.Reader(key)); err != nil {
+			return err
 		}
 	}
 
@@ -64,75 +80,150 @@ func (reader *queryReader) Path() string {
 
 func (reader *queryReader) Reader(key string) restrpc.Reader {
 	return &queryReader{
-		path:   append(reader.path, key),
+		path:   childPath(reader.path, key),
 		values: reader.values,
 	}
 }
 
-type jsonReader struct {
-	path      []string
-	container *gabs.Container
-}
+// Elements returns repeated values (ids=1&ids=2) or indexed keys (items.0.name=x) in index order
+func (reader *queryReader) Elements() ([]restrpc.Reader, error) {
+	path := reader.Path()
 
-// NewJSONReader .
-func NewJSONReader(content []byte) (restrpc.Reader, error) {
-	container, err := gabs.ParseJSON(content)
+	if values := reader.values[path]; len(values) > 0 {
+		var elements []restrpc.Reader
 
-	if err != nil {
-		return nil, xerrors.Wrapf(err, "parse input json content error: %s", string(content))
+		for _, value := range values {
+			elements = append(elements, &queryReader{
+				path:   reader.path,
+				values: url.Values{path: []string{value}},
+			})
+		}
+
+		return elements, nil
 	}
 
-	return &jsonReader{
-		container: container,
-	}, nil
-}
+	prefix := path + "."
+
+	if path == "" {
+		prefix = ""
+	}
 
-func (reader *jsonReader) Search(key string) ([]string, error) {
+	indexes := make(map[int]bool)
 
-	path := strings.Join(append(reader.path, key), ".")
+	for key := range reader.values {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		segment := strings.SplitN(strings.TrimPrefix(key, prefix), ".", 2)[0]
+
+		index, err := strconv.Atoi(segment)
+
+		if err != nil || index < 0 {
+			continue
+		}
+
+		indexes[index] = true
+	}
+
+	var sorted []int
 
-	value := reader.container.Path(path).String()
+	for index := range indexes {
+		sorted = append(sorted, index)
+	}
+
+	sort.Ints(sorted)
+
+	var elements []restrpc.Reader
+
+	for _, index := range sorted {
+		elements = append(elements, reader.Reader(strconv.Itoa(index)))
+	}
 
-	return []string{value}, nil
+	return elements, nil
 }
-func (reader *jsonReader) Get() ([]string, error) {
 
-	path := strings.Join(reader.path, ".")
+// childPath returns a copy of path with key appended, sibling readers never share the backing array
+func childPath(path []string, key string) []string {
+	child := make([]string, len(path), len(path)+1)
+	copy(child, path)
+
+	return append(child, key)
+}
 
-	value := reader.container.Path(path).String()
+// Presence parameter presence
+type Presence int
 
-	return []string{value}, nil
+// Parameter presence values
+const (
+	Missing Presence = iota // parameter is not sent
+	Null                    // parameter is sent as json null
+	Present                 // parameter is sent, possibly empty
+)
+
+// PresenceReader optional restrpc.Reader extension reporting parameter presence
+type PresenceReader interface {
+	Presence() Presence
 }
-func (reader *jsonReader) Range(f func(key string, reader restrpc.Reader) error) error {
 
-	path := strings.Join(reader.path, ".")
+// KindReader optional restrpc.Reader extension reporting the json type and byte offset of the value,
+// scalar parameters reject values of another type, e.g. an object bound to a string
+type KindReader interface {
+	Kind() (kind Kind, offset int, ok bool)
+}
 
-	children, err := reader.container.Path(path).ChildrenMap()
+// ElementReader optional restrpc.Reader extension returning the array element readers, nil if the path is missing.
+// Readers without it bind arrays from the Get values
+type ElementReader interface {
+	Elements() ([]restrpc.Reader, error)
+}
 
-	if err != nil {
-		return xerrors.Wrapf(err, "get path %s children map error", path)
+// readElements returns the array element readers of reader, each Get value is an element if reader is not an ElementReader
+func readElements(reader restrpc.Reader) ([]restrpc.Reader, error) {
+	if elementReader, ok := reader.(ElementReader); ok {
+		return elementReader.Elements()
 	}
 
-	for key := range children {
-		err := f(key, &jsonReader{
-			path:      append(reader.path, key),
-			container: reader.container,
-		})
+	values, err := reader.Get()
 
-		if err != nil {
-			return err
-		}
+	if err != nil || values == nil {
+		return nil, err
 	}
 
-	return nil
+	path := reader.Path()
+
+	elements := make([]restrpc.Reader, 0, len(values))
+
+	for _, value := range values {
+		elements = append(elements, &queryReader{
+			path:   strings.Split(path, "."),
+			values: url.Values{path: []string{value}},
+		})
+	}
+
+	return elements, nil
 }
-func (reader *jsonReader) Path() string {
-	return strings.Join(reader.path, ".")
+
+// RawReader optional restrpc.Reader extension returning the raw json value, used by json.Unmarshaler
+type RawReader interface {
+	Raw() []byte
 }
 
-func (reader *jsonReader) Reader(key string) restrpc.Reader {
-	return &jsonReader{
-		path:      append(reader.path, key),
-		container: reader.container,
+// Presence .
+func (reader *queryReader) Presence() Presence {
+	path := reader.Path()
+
+	if _, ok := reader.values[path]; ok {
+		return Present
 	}
+
+	prefix := path + "."
+
+	for key := range reader.values {
+		if path == "" || strings.HasPrefix(key, prefix) {
+			return Present
+		}
+	}
+
+	return Missing
 }
@@ -0,0 +1,298 @@
+package validator
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/dynamicgo/restrpc"
+	"github.com/dynamicgo/xerrors"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	writerType          = reflect.TypeOf((*restrpc.Writer)(nil)).Elem()
+)
+
+// IsText check whether paramT is bound from a single text value instead of its kind:
+// registered binders, time.Time, time.Duration, url.URL, encoding.TextUnmarshaler and json.Unmarshaler,
+// see Register for the precedence
+func IsText(paramT reflect.Type) bool {
+	if _, ok := lookupBinder(paramT); ok {
+		return true
+	}
+
+	switch paramT {
+	case timeType, durationType, urlType:
+		return true
+	}
+
+	ptrT := reflect.PtrTo(paramT)
+
+	return ptrT.Implements(textUnmarshalerType) || ptrT.Implements(jsonUnmarshalerType)
+}
+
+type textValidator struct {
+	metadata *Metadata
+}
+
+func (validator *textValidator) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
+	params, err := reader.Get()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+
+	if rawReader, ok := reader.(RawReader); ok {
+		raw = rawReader.Raw()
+	}
+
+	var values []reflect.Value
+
+	for _, param := range params {
+
+		value, err := parseText(param, raw, paramT, validator.metadata)
+
+		if err != nil {
+			return nil, xerrors.Wrapf(ErrFormat, "parse %s parameter %s with value %s error: %s", paramT, reader.Path(), param, err)
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// unquote returns the content of JSON string literals
+func unquote(param string) string {
+	if len(param) < 2 || param[0] != '"' {
+		return param
+	}
+
+	var text string
+
+	if err := json.Unmarshal([]byte(param), &text); err != nil {
+		return param
+	}
+
+	return text
+}
+
+// parseText parse param text, raw is the json value of param if it is read from json
+func parseText(text string, raw []byte, paramT reflect.Type, metadata *Metadata) (reflect.Value, error) {
+	if metadata == nil {
+		metadata = &Metadata{}
+	}
+
+	if binder, ok := lookupBinder(paramT); ok {
+		bound, err := binder.Bind(text, metadata)
+
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		value := reflect.ValueOf(bound)
+
+		if !value.IsValid() {
+			return reflect.Value{}, xerrors.Wrapf(ErrInvalidType, "binder returns nil, expect %s", paramT)
+		}
+
+		if value.Type() == paramT || (value.Kind() == reflect.Ptr && value.Type().Elem() == paramT && !value.IsNil()) {
+			return value, nil
+		}
+
+		return reflect.Value{}, xerrors.Wrapf(ErrInvalidType, "binder returns %s, expect %s", value.Type(), paramT)
+	}
+
+	switch paramT {
+	case timeType:
+		if metadata.Unix || metadata.UnixMS {
+			number, err := strconv.ParseInt(text, 10, 64)
+
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			if metadata.UnixMS {
+				return reflect.ValueOf(time.Unix(number/1000, number%1000*int64(time.Millisecond))), nil
+			}
+
+			return reflect.ValueOf(time.Unix(number, 0)), nil
+		}
+
+		layout := time.RFC3339Nano
+
+		if metadata.Layout != "" {
+			layout = metadata.Layout
+		}
+
+		t, err := time.Parse(layout, text)
+
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		return reflect.ValueOf(t), nil
+	case durationType:
+		duration, err := time.ParseDuration(text)
+
+		if err != nil {
+			// plain integer nanoseconds
+			nanoseconds, intErr := strconv.ParseInt(text, 10, 64)
+
+			if intErr != nil {
+				return reflect.Value{}, err
+			}
+
+			duration = time.Duration(nanoseconds)
+		}
+
+		return reflect.ValueOf(duration), nil
+	case urlType:
+		u, err := url.Parse(text)
+
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		return reflect.ValueOf(u).Elem(), nil
+	}
+
+	ptr := reflect.New(paramT)
+
+	if unmarshaler, ok := ptr.Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(text)); err != nil {
+			return reflect.Value{}, err
+		}
+
+		return ptr.Elem(), nil
+	}
+
+	// query values are plain text, pass them as JSON strings unless they are valid JSON, e.g. [1,2]
+	if raw == nil {
+		if raw = []byte(text); !json.Valid(raw) {
+			raw, _ = json.Marshal(text)
+		}
+	}
+
+	if err := ptr.Interface().(json.Unmarshaler).UnmarshalJSON(raw); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return ptr.Elem(), nil
+}
+
+// FormatValue returns the JSON literal of value bound by text (see Validate), ok is false for other types,
+// the client writer uses it to encode parameters symmetrically
+func FormatValue(value reflect.Value, metadata *Metadata) (literal []byte, ok bool, err error) {
+	if metadata == nil {
+		metadata = &Metadata{}
+	}
+
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() || !IsText(value.Type().Elem()) {
+			return nil, false, nil
+		}
+
+		value = value.Elem()
+	}
+
+	if binder, ok := lookupBinder(value.Type()); ok {
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+
+		text, err := binder.Format(ptr.Interface(), metadata)
+
+		if err != nil {
+			return nil, true, err
+		}
+
+		literal, err = json.Marshal(text)
+
+		return literal, true, err
+	}
+
+	if reflect.PtrTo(value.Type()).Implements(writerType) {
+		return nil, false, nil
+	}
+
+	switch value.Type() {
+	case timeType:
+		t := value.Interface().(time.Time)
+
+		if metadata.UnixMS {
+			return []byte(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)), true, nil
+		}
+
+		if metadata.Unix {
+			return []byte(strconv.FormatInt(t.Unix(), 10)), true, nil
+		}
+
+		layout := time.RFC3339Nano
+
+		if metadata.Layout != "" {
+			layout = metadata.Layout
+		}
+
+		literal, err = json.Marshal(t.Format(layout))
+
+		return literal, true, err
+	case durationType:
+		literal, err = json.Marshal(time.Duration(value.Int()).String())
+
+		return literal, true, err
+	case urlType:
+		u := value.Interface().(url.URL)
+
+		literal, err = json.Marshal(u.String())
+
+		return literal, true, err
+	}
+
+	if !IsText(value.Type()) {
+		return nil, false, nil
+	}
+
+	ptr := reflect.New(value.Type())
+	ptr.Elem().Set(value)
+
+	if marshaler, isMarshaler := ptr.Interface().(encoding.TextMarshaler); isMarshaler {
+		text, err := marshaler.MarshalText()
+
+		if err != nil {
+			return nil, true, err
+		}
+
+		literal, err = json.Marshal(string(text))
+
+		return literal, true, err
+	}
+
+	if marshaler, isMarshaler := ptr.Interface().(json.Marshaler); isMarshaler {
+		literal, err = marshaler.MarshalJSON()
+
+		return literal, true, err
+	}
+
+	return nil, false, nil
+}
+
+// FormatText returns the query text of value bound by text, see FormatValue
+func FormatText(value reflect.Value, metadata *Metadata) (string, bool, error) {
+	literal, ok, err := FormatValue(value, metadata)
+
+	if !ok || err != nil {
+		return "", ok, err
+	}
+
+	return unquote(string(literal)), true, nil
+}
@@ -3,9 +3,14 @@ package validator
 import (
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dynamicgo/restrpc"
 	"github.com/dynamicgo/xerrors"
@@ -15,8 +20,18 @@ import (
 var (
 	ErrInvalidType = errors.New("invalid parameter type")
 	ErrNumber      = errors.New("parse number error")
+	ErrOverflow    = errors.New("number overflow")
+	ErrFraction    = errors.New("fractional value for integer")
 	ErrKey         = errors.New("restrpc map only support string key")
 	ErrInner       = errors.New("inner error")
+	ErrLength      = errors.New("array length error")
+	ErrFormat      = errors.New("parse text value error")
+	ErrCollision   = errors.New("parameter name collision")
+	ErrJSONSyntax  = errors.New("json syntax error")
+	ErrJSONDepth   = errors.New("json nesting too deep")
+	ErrJSONLength  = errors.New("json array too long")
+	ErrJSONKeys    = errors.New("json object has too many keys")
+	ErrTag         = errors.New("malformed rest tag")
 )
 
 // MetadataTag .
@@ -24,37 +39,89 @@ const MetadataTag = "rest"
 
 // Metadata .
 type Metadata struct {
-	Skipped  bool   // skipped field
-	Required bool   // required parameter flag
-	Name     string // parameter name
+	Skipped  bool    // skipped field
+	Required bool    // required parameter flag
+	Name     string  // parameter name
+	Layout   string  // time.Time layout, "layout=2006-01-02", default time.RFC3339Nano
+	Unix     bool    // time.Time as unix seconds
+	UnixMS   bool    // time.Time as unix milliseconds
+	Default  *string // default value used when the parameter is missing, "default=20", slice values "default=a|b"
 }
 
-// ParseMetadata .
+// DefaultValues returns the default parameter values, slice values are separated by '|'
+// and $VAR or ${VAR} are expanded from the environment, e.g. "default=${PAGE_SIZE}",
+// Validate expands them once per type when the type is first used
+func (metadata *Metadata) DefaultValues() []string {
+	if metadata.Default == nil {
+		return nil
+	}
+
+	return strings.Split(os.ExpandEnv(*metadata.Default), "|")
+}
+
+// ParseMetadata parse the rest tag, see ParseTag, malformed tags are reported by ParseTag and Fields
 func ParseMetadata(tag string) *Metadata {
+	metadata, _ := ParseTag(tag)
+
+	return metadata
+}
+
+// ParseTag parse the rest tag "name,option,...", the options are required, -, unix, unixms, layout= and default=.
+// The name is the only bare token, a second one is ErrTag. The layout and default values run up to the next option,
+// so they may contain commas, e.g. `rest:"at,layout=Mon, 02 Jan 2006 15:04:05 MST"` or `rest:"q,default=a,b"`
+// is the single value "a,b", slice defaults are separated by '|', e.g. `rest:"tags,default=a|b"`
+func ParseTag(tag string) (*Metadata, error) {
 	metadata := &Metadata{}
 
 	if tag == "" {
-		return metadata
+		return metadata, nil
 	}
 
-	tokens := strings.Split(tag, ",")
+	named := false
 
-	for _, token := range tokens {
-		switch token {
-		case "required":
+	var value *string // option value continued by the following bare tokens
+
+	for _, token := range strings.Split(tag, ",") {
+		switch {
+		case token == "required":
 			metadata.Required = true
-		case "-":
+		case token == "-":
 			metadata.Skipped = true
+		case token == "unix":
+			metadata.Unix = true
+		case token == "unixms":
+			metadata.UnixMS = true
+		case strings.HasPrefix(token, "layout="):
+			metadata.Layout = strings.TrimPrefix(token, "layout=")
+			value = &metadata.Layout
+			continue
+		case strings.HasPrefix(token, "default="):
+			defaultValue := strings.TrimPrefix(token, "default=")
+			metadata.Default = &defaultValue
+			value = metadata.Default
+			continue
+		case value != nil:
+			*value += "," + token
+			continue
+		case !named:
+			metadata.Name, named = token, true
 		default:
-			metadata.Name = token
+			return metadata, xerrors.Wrapf(ErrTag, "unexpected token %q after name %q in rest tag %q", token, metadata.Name, tag)
 		}
+
+		value = nil
 	}
 
-	return metadata
+	return metadata, nil
 }
 
 // Validate validate parameter with reflect type and reader
 func Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
+	return validate(reader, paramT, nil)
+}
+
+// validate validate parameter with the struct field metadata, see Register for the precedence
+func validate(reader restrpc.Reader, paramT reflect.Type, metadata *Metadata) ([]reflect.Value, error) {
 
 	if paramT.Kind() == reflect.Ptr {
 		paramT = paramT.Elem()
@@ -62,6 +129,15 @@ func Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, erro
 
 	var validator restrpc.Validator
 
+	if _, ok := lookupBinder(paramT); !ok && reflect.PtrTo(paramT).Implements(reflect.TypeOf(&validator).Elem()) {
+		validator = reflect.New(paramT).Interface().(restrpc.Validator)
+		return validator.Validate(reader, paramT)
+	}
+
+	if IsText(paramT) {
+		return (&textValidator{metadata: metadata}).Validate(reader, paramT)
+	}
+
 	switch paramT.Kind() {
 	case reflect.Int, reflect.Int8,
 		reflect.Int16, reflect.Int32,
@@ -74,17 +150,12 @@ func Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, erro
 		validator = &structValidator{}
 	case reflect.String:
 		validator = &stringValidator{}
-	case reflect.Array:
-		validator = &arrayValidator{}
+	case reflect.Array, reflect.Slice:
+		validator = &arrayValidator{metadata: metadata}
 	case reflect.Map:
 		validator = &mapValidator{}
 	case reflect.Bool:
 		validator = &boolValidator{}
-	default:
-		if paramT.Implements(reflect.TypeOf(&validator).Elem()) {
-			value := reflect.New(paramT)
-			validator = value.Interface().(restrpc.Validator)
-		}
 	}
 
 	if validator == nil {
@@ -107,13 +178,42 @@ type stringValidator struct {
 }
 
 type arrayValidator struct {
+	metadata *Metadata // element metadata, e.g. time layout
 }
 
 type mapValidator struct {
 }
 
+// checkKind reject json values of another type than expect or accept, readers without KindReader are not typed
+func checkKind(reader restrpc.Reader, expect Kind, accept ...Kind) error {
+	kindReader, ok := reader.(KindReader)
+
+	if !ok {
+		return nil
+	}
+
+	kind, offset, ok := kindReader.Kind()
+
+	if !ok || kind == KindNull || kind == expect {
+		return nil
+	}
+
+	for _, accepted := range accept {
+		if kind == accepted {
+			return nil
+		}
+	}
+
+	return xerrors.Wrapf(ErrInvalidType, "expect %s at path %s offset %d, got %s", expect, reader.Path(), offset, kind)
+}
+
 func (validator *boolValidator) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
 
+	// quoted booleans are parsed like query values
+	if err := checkKind(reader, KindBool, KindString); err != nil {
+		return nil, err
+	}
+
 	params, err := reader.Get()
 
 	if err != nil {
@@ -138,6 +238,11 @@ func (validator *boolValidator) Validate(reader restrpc.Reader, paramT reflect.T
 
 func (validator *numberValidator) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
 
+	// quoted numbers are parsed like query values, e.g. int64 ids beyond javascript numbers
+	if err := checkKind(reader, KindNumber, KindString); err != nil {
+		return nil, err
+	}
+
 	params, err := reader.Get()
 
 	if err != nil {
@@ -148,78 +253,216 @@ func (validator *numberValidator) Validate(reader restrpc.Reader, paramT reflect
 
 	for _, param := range params {
 
-		number, err := strconv.ParseFloat(param, 64)
+		value, err := parseNumber(param, paramT)
 
 		if err != nil {
-			return nil, xerrors.Wrapf(ErrNumber, "parse number parameter %s with value %s error", reader.Path(), param)
+			return nil, xerrors.Wrapf(err, "parse %s parameter %s with value %s error", paramT, reader.Path(), param)
 		}
 
-		values = append(values, reflect.ValueOf(number))
+		values = append(values, value)
 	}
 
 	return values, nil
 }
 
-func (validator *structValidator) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
+// parseNumber parse param as paramT kind number, integers are parsed exactly with the kind bit size
+func parseNumber(param string, paramT reflect.Type) (reflect.Value, error) {
+	switch paramT.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := strconv.ParseInt(param, 10, paramT.Bits())
 
-	mapValue := reflect.New(paramT)
+		if err != nil {
+			integer, convErr := integral(param, err)
 
-	mapValue = mapValue.Elem()
+			if convErr != nil {
+				return reflect.Value{}, convErr
+			}
 
-	for i := 0; i < paramT.NumField(); i++ {
-		field := paramT.Field(i)
+			if number, err = strconv.ParseInt(integer, 10, paramT.Bits()); err != nil {
+				return reflect.Value{}, ErrOverflow
+			}
+		}
 
-		metadata := ParseMetadata(field.Tag.Get(MetadataTag))
+		return reflect.ValueOf(number).Convert(paramT), nil
+	case reflect.Float32, reflect.Float64:
+		number, err := strconv.ParseFloat(param, paramT.Bits())
 
-		name := strings.ToLower(field.Name)
+		if err != nil {
+			if isRange(err) {
+				return reflect.Value{}, ErrOverflow
+			}
 
-		if metadata.Name != "" {
-			name = metadata.Name
+			return reflect.Value{}, ErrNumber
 		}
 
-		fieldReader := reader.Reader(name)
-
-		values, err := Validate(fieldReader, field.Type)
+		return reflect.ValueOf(number).Convert(paramT), nil
+	default:
+		number, err := strconv.ParseUint(param, 10, paramT.Bits())
 
 		if err != nil {
-			return nil, err
-		}
+			integer, convErr := integral(param, err)
 
-		if len(values) == 0 {
-			if metadata.Required {
-				return nil, fmt.Errorf("expect param %s", fieldReader.Path())
+			if convErr != nil {
+				return reflect.Value{}, convErr
 			}
 
-			continue
+			if strings.HasPrefix(integer, "-") {
+				return reflect.Value{}, ErrOverflow
+			}
+
+			if number, err = strconv.ParseUint(integer, 10, paramT.Bits()); err != nil {
+				return reflect.Value{}, ErrOverflow
+			}
+		}
+
+		return reflect.ValueOf(number).Convert(paramT), nil
+	}
+}
+
+func isRange(err error) bool {
+	numErr, ok := err.(*strconv.NumError)
+	return ok && numErr.Err == strconv.ErrRange
+}
+
+// maxIntegralLength bounds the float literals of integers, no 64 bit integer needs more digits
+const maxIntegralLength = 64
+
+// integral returns the decimal integer of a number written as float, e.g. 1e3 or 2.0,
+// fractional values are rejected. The magnitude is checked with strconv.ParseFloat before
+// the exact conversion, huge exponents such as 1e10000000 would take seconds with big.Float
+func integral(param string, err error) (string, error) {
+	if isRange(err) || len(param) > maxIntegralLength {
+		return "", ErrOverflow
+	}
+
+	float, err := strconv.ParseFloat(param, 64)
+
+	if err != nil {
+		if isRange(err) {
+			return "", ErrOverflow
+		}
+
+		return "", ErrNumber
+	}
+
+	if math.IsInf(float, 0) || math.IsNaN(float) {
+		return "", ErrNumber
+	}
+
+	// float64 rounds the largest uint64 up to 2^64, the exact range is checked by the caller
+	if math.Abs(float) >= math.Ldexp(1, 65) {
+		return "", ErrOverflow
+	}
+
+	// underflow, e.g. 1e-10000000, is a fraction unless every mantissa digit is zero
+	if float == 0 {
+		mantissa := param
+
+		if i := strings.IndexAny(param, "eE"); i >= 0 {
+			mantissa = param[:i]
 		}
 
-		fieldValue := mapValue.Field(i)
+		if strings.ContainsAny(mantissa, "123456789") {
+			return "", ErrFraction
+		}
+
+		return "0", nil
+	}
+
+	number, ok := new(big.Float).SetString(param)
+
+	if !ok {
+		return "", ErrNumber
+	}
+
+	if !number.IsInt() {
+		return "", ErrFraction
+	}
+
+	integer, _ := number.Int(nil)
 
-		value := values[0]
+	return integer.String(), nil
+}
+
+func (validator *structValidator) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
+
+	fields, err := Fields(paramT)
 
-		fieldType := field.Type
+	if err != nil {
+		return nil, err
+	}
 
-		if field.Type.Kind() == reflect.Ptr {
+	mapValue := reflect.New(paramT)
+
+	mapValue = mapValue.Elem()
+
+	var presenceSet PresenceSet
+
+	if index, ok := PresenceIndex(paramT); ok {
+		presenceSet = make(PresenceSet)
+		mapValue.FieldByIndex(index).Set(reflect.ValueOf(presenceSet))
+	}
 
-			fieldType = fieldType.Elem()
+	for _, field := range fields {
+		metadata := field.Metadata
 
-			if value.Type().Kind() != reflect.Ptr {
-				value = value.Addr()
+		fieldReader := reader.Reader(field.Name)
+
+		state, known := presence(fieldReader)
+
+		var values []reflect.Value
+
+		// null is not bound, e.g. a null nested struct pointer stays nil, nor is a missing pointer
+		if state != Null && !(state == Missing && isNilPointer(field.Type, known) && isEmpty(fieldReader)) {
+			values, err = validate(fieldReader, field.Type, metadata)
+
+			if err != nil {
+				return nil, fieldError(fieldReader.Path(), err)
 			}
+		}
+
+		if !known && len(values) > 0 {
+			state = Present
+		}
 
+		if presenceSet != nil && state != Missing {
+			presenceSet[field.Name] = state
 		}
 
-		if value.Type().Kind() != fieldType.Kind() {
-			value = value.Convert(field.Type)
+		// defaults fill missing parameters only, null keeps the zero value
+		if len(values) == 0 && metadata.Default != nil && state == Missing {
+			values, err = defaultValue(fieldReader.Path(), field.Type, metadata, field.defaults)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(values) == 0 {
+			if metadata.Required {
+				return nil, &restrpc.FieldError{Field: fieldReader.Path(), Detail: fmt.Sprintf("expect param %s", fieldReader.Path())}
+			}
+
+			continue
 		}
 
-		fieldValue.Set(value)
+		assign(fieldByIndex(mapValue, field.Index), values[0])
 	}
 
 	return []reflect.Value{mapValue}, nil
 }
 
+// isNilPointer check whether a missing paramT parameter is left nil: pointers of readers reporting presence,
+// a missing nested struct pointer must not be bound to a zero struct, and recursive pointers of any reader
+func isNilPointer(paramT reflect.Type, known bool) bool {
+	return (known && paramT.Kind() == reflect.Ptr) || isRecursive(paramT)
+}
+
 func (validator *stringValidator) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
+	if err := checkKind(reader, KindString); err != nil {
+		return nil, err
+	}
+
 	params, err := reader.Get()
 
 	if err != nil {
@@ -238,9 +481,156 @@ func (validator *stringValidator) Validate(reader restrpc.Reader, paramT reflect
 
 func (validator *arrayValidator) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
 
-	paramT = paramT.Elem()
+	elements, err := readElements(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// missing, an empty array is bound to an empty slice
+	if elements == nil {
+		return nil, nil
+	}
+
+	var arrayValue reflect.Value
+
+	if paramT.Kind() == reflect.Array {
+		if len(elements) > paramT.Len() {
+			return nil, xerrors.Wrapf(ErrLength, "array parameter %s expect at most %d elements, got %d", reader.Path(), paramT.Len(), len(elements))
+		}
+
+		arrayValue = reflect.New(paramT).Elem()
+	} else {
+		arrayValue = reflect.MakeSlice(paramT, len(elements), len(elements))
+	}
+
+	for i, element := range elements {
+		values, err := validate(element, paramT.Elem(), validator.metadata)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		assign(arrayValue.Index(i), values[0])
+	}
+
+	return []reflect.Value{arrayValue}, nil
+}
+
+// fieldError report the validation failures of the parameter at path as restrpc.FieldError,
+// errors of nested parameters are already reported with their own path
+func fieldError(path string, err error) error {
+	var fieldErr *restrpc.FieldError
+
+	if errors.As(err, &fieldErr) {
+		return err
+	}
+
+	for _, target := range []error{ErrInvalidType, ErrNumber, ErrOverflow, ErrFraction, ErrFormat, ErrLength, ErrInner} {
+		if errors.Is(err, target) {
+			return &restrpc.FieldError{Field: path, Detail: err.Error(), Err: err}
+		}
+	}
+
+	return err
+}
+
+// defaultValue bind the default values as if they were sent in the query
+func defaultValue(path string, paramT reflect.Type, metadata *Metadata, defaults []string) ([]reflect.Value, error) {
+	reader := &queryReader{
+		path:   strings.Split(path, "."),
+		values: url.Values{path: defaults},
+	}
+
+	values, err := validate(reader, paramT, metadata)
+
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "invalid default value of %s", path)
+	}
+
+	return values, nil
+}
+
+// literalDefault returns the default bound to valueT without pointers, invalid if the default is invalid
+func literalDefault(valueT reflect.Type, metadata *Metadata, defaults []string) reflect.Value {
+	for valueT.Kind() == reflect.Ptr {
+		valueT = valueT.Elem()
+	}
+
+	values, err := defaultValue("default", valueT, metadata, defaults)
+
+	if err != nil || len(values) == 0 {
+		return reflect.Value{}
+	}
+
+	expected := values[0]
+
+	for expected.Kind() == reflect.Ptr {
+		expected = expected.Elem()
+	}
+
+	return expected
+}
+
+// IsDefault check whether value equals to the literal metadata default, the client omits such parameters.
+// Defaults with $ are expanded from the server environment, they are never reported as default.
+// The default is bound on every call, Field.IsDefault binds it once per type
+func IsDefault(value reflect.Value, metadata *Metadata) bool {
+	if metadata == nil || metadata.Default == nil || strings.Contains(*metadata.Default, "$") {
+		return false
+	}
+
+	return equalDefault(value, literalDefault(value.Type(), metadata, metadata.DefaultValues()))
+}
+
+// equalDefault check whether value equals to the literal default expected
+func equalDefault(value reflect.Value, expected reflect.Value) bool {
+	if !expected.IsValid() {
+		return false
+	}
 
-	return Validate(reader, paramT)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return false
+		}
+
+		value = value.Elem()
+	}
+
+	if value.Type() != expected.Type() {
+		return false
+	}
+
+	if value.Type() == timeType {
+		return value.Interface().(time.Time).Equal(expected.Interface().(time.Time))
+	}
+
+	return reflect.DeepEqual(value.Interface(), expected.Interface())
+}
+
+// assign set validated value to struct field or array element, allocating pointers and converting named types
+func assign(target reflect.Value, value reflect.Value) {
+	targetT := target.Type()
+
+	if targetT.Kind() == reflect.Ptr {
+		if value.Kind() != reflect.Ptr {
+			ptr := reflect.New(targetT.Elem())
+			ptr.Elem().Set(value.Convert(targetT.Elem()))
+			value = ptr
+		}
+	} else if value.Kind() == reflect.Ptr && targetT.Kind() != reflect.Ptr {
+		value = value.Elem()
+	}
+
+	if value.Type() != targetT {
+		value = value.Convert(targetT)
+	}
+
+	target.Set(value)
 }
 
 func (validator *mapValidator) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
@@ -252,7 +642,11 @@ func (validator *mapValidator) Validate(reader restrpc.Reader, paramT reflect.Ty
 
 	valueT := paramT.Elem()
 
-	mapValue := reflect.New(paramT)
+	if presence, ok := reader.(PresenceReader); ok && presence.Presence() != Present {
+		return nil, nil
+	}
+
+	mapValue := reflect.MakeMap(paramT)
 
 	path := reader.Path()
 
@@ -268,10 +662,18 @@ func (validator *mapValidator) Validate(reader restrpc.Reader, paramT reflect.Ty
 			return xerrors.Wrapf(ErrInner, "expect map %s key %s value", path, key)
 		}
 
-		mapValue.SetMapIndex(reflect.ValueOf(key), values[0])
+		value := reflect.New(valueT).Elem()
+
+		assign(value, values[0])
+
+		mapValue.SetMapIndex(reflect.ValueOf(key).Convert(keyT), value)
 
 		return nil
 	})
 
-	return []reflect.Value{mapValue}, err
+	if err != nil {
+		return nil, err
+	}
+
+	return []reflect.Value{mapValue}, nil
 }
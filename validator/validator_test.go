@@ -2,10 +2,18 @@ package validator
 
 import (
 	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
 	"net/url"
+	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dynamicgo/restrpc"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,7 +31,7 @@ type TestB struct {
 var input = url.Values{
 	"a.a": []string{"hello"},
 	"a.b": []string{"world"},
-	"c":   []string{"1.899999"},
+	"c":   []string{"1"},
 }
 
 func TestQueryValidator(t *testing.T) {
@@ -50,3 +58,982 @@ func printResult(v interface{}) string {
 
 	return string(val)
 }
+
+type testNumbers struct {
+	I   int
+	I8  int8
+	I16 int16
+	I32 int32
+	I64 int64
+	U   uint
+	U8  uint8
+	U16 uint16
+	U32 uint32
+	U64 uint64
+	F32 float32
+	F64 float64
+	P   *int64
+}
+
+func TestNumberValidator(t *testing.T) {
+	var param *testNumbers
+
+	values, err := Validate(NewQueryReader(url.Values{
+		"i":   []string{"-9223372036854775808"},
+		"i8":  []string{"-128"},
+		"i16": []string{"32767"},
+		"i32": []string{"2e3"},
+		"i64": []string{"9007199254740993"},
+		"u":   []string{"18446744073709551615"},
+		"u8":  []string{"255"},
+		"u16": []string{"65535"},
+		"u32": []string{"4294967295"},
+		"u64": []string{"18446744073709551615"},
+		"f32": []string{"1.5"},
+		"f64": []string{"-2.25"},
+		"p":   []string{"9007199254740993"},
+	}), reflect.TypeOf(param))
+
+	require.NoError(t, err)
+
+	p := int64(9007199254740993)
+
+	require.Equal(t, testNumbers{
+		I:   -9223372036854775808,
+		I8:  -128,
+		I16: 32767,
+		I32: 2000,
+		I64: 9007199254740993,
+		U:   18446744073709551615,
+		U8:  255,
+		U16: 65535,
+		U32: 4294967295,
+		U64: 18446744073709551615,
+		F32: 1.5,
+		F64: -2.25,
+		P:   &p,
+	}, values[0].Interface())
+}
+
+func TestNumberValidatorJSON(t *testing.T) {
+	var param *testNumbers
+
+	reader, err := NewJSONReader([]byte(`{"i":1,"i8":-1,"i16":2,"i32":3,"i64":9007199254740993,"u":4,"u8":5,"u16":6,"u32":7,"u64":18446744073709551615,"f32":0.5,"f64":1e3,"p":-9007199254740993}`))
+	require.NoError(t, err)
+
+	values, err := Validate(reader, reflect.TypeOf(param))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testNumbers)
+
+	require.Equal(t, int64(9007199254740993), result.I64)
+	require.Equal(t, uint64(18446744073709551615), result.U64)
+	require.Equal(t, int8(-1), result.I8)
+	require.Equal(t, float64(1000), result.F64)
+	require.Equal(t, int64(-9007199254740993), *result.P)
+}
+
+func TestNumberValidatorErrors(t *testing.T) {
+	var param *testNumbers
+
+	for key, cases := range map[string]map[string]error{
+		"i":   {"1.9": ErrFraction, "abc": ErrNumber, "9223372036854775808": ErrOverflow, "1e-10000000": ErrFraction, "Inf": ErrNumber},
+		"i8":  {"128": ErrOverflow, "-129": ErrOverflow, "1e3": ErrOverflow},
+		"i16": {"32768": ErrOverflow},
+		"i32": {"2147483648": ErrOverflow, "0.5": ErrFraction},
+		"i64": {"1e19": ErrOverflow, "1e10000000": ErrOverflow, "-1e10000000": ErrOverflow, "0.1e400": ErrOverflow},
+		"u":   {"-1": ErrOverflow, "1.5": ErrFraction},
+		"u8":  {"256": ErrOverflow},
+		"u16": {"65536": ErrOverflow},
+		"u32": {"4294967296": ErrOverflow},
+		"u64": {"18446744073709551616": ErrOverflow, "x": ErrNumber, "1e10000000": ErrOverflow},
+		"f32": {"1e39": ErrOverflow, "x": ErrNumber},
+		"f64": {"1e309": ErrOverflow},
+	} {
+		for value, expected := range cases {
+			_, err := Validate(NewQueryReader(url.Values{key: []string{value}}), reflect.TypeOf(param))
+
+			require.Error(t, err, "%s=%s", key, value)
+			require.True(t, errors.Is(err, expected), "%s=%s: %s", key, value, err)
+			require.Contains(t, err.Error(), "parameter "+key+" ", "%s=%s", key, value)
+		}
+	}
+}
+
+func TestNumberValidatorExponent(t *testing.T) {
+	var param *testNumbers
+
+	start := time.Now()
+
+	for _, value := range []string{"1e10000000", "1" + strings.Repeat("0", 100000) + "e-99999", "1e-10000000"} {
+		_, err := Validate(NewQueryReader(url.Values{"i64": []string{value}}), reflect.TypeOf(param))
+		require.Error(t, err)
+	}
+
+	require.True(t, time.Since(start) < 100*time.Millisecond, "%s", time.Since(start))
+
+	values, err := Validate(NewQueryReader(url.Values{"i64": []string{"0e-10000000"}, "u64": []string{"1.8446744073709551615e19"}}), reflect.TypeOf(param))
+	require.NoError(t, err)
+
+	require.Equal(t, int64(0), values[0].Interface().(testNumbers).I64)
+	require.Equal(t, uint64(18446744073709551615), values[0].Interface().(testNumbers).U64)
+}
+
+type testItem struct {
+	Name  string
+	Count int
+}
+
+type testSlices struct {
+	IDs   []int64 `rest:"ids"`
+	Tags  []string
+	Items []testItem
+	Ptrs  []*testItem
+	Fixed [2]uint8
+}
+
+func TestSliceValidatorQuery(t *testing.T) {
+	var param *testSlices
+
+	values, err := Validate(NewQueryReader(url.Values{
+		"ids":           []string{"1", "9007199254740993"},
+		"tags":          []string{"a", "b", "c"},
+		"items.1.name":  []string{"y"},
+		"items.0.name":  []string{"x"},
+		"items.0.count": []string{"2"},
+		"ptrs.0.count":  []string{"3"},
+		"fixed.1":       []string{"7"},
+		"fixed.0":       []string{"6"},
+	}), reflect.TypeOf(param))
+
+	require.NoError(t, err)
+
+	require.Equal(t, testSlices{
+		IDs:   []int64{1, 9007199254740993},
+		Tags:  []string{"a", "b", "c"},
+		Items: []testItem{{Name: "x", Count: 2}, {Name: "y"}},
+		Ptrs:  []*testItem{{Count: 3}},
+		Fixed: [2]uint8{6, 7},
+	}, values[0].Interface())
+}
+
+func TestSliceValidatorJSON(t *testing.T) {
+	var param *testSlices
+
+	reader, err := NewJSONReader([]byte(`{"ids":[1,2,3],"items":[{"count":1},{"count":2}],"ptrs":[{"count":3}],"fixed":[4,5]}`))
+	require.NoError(t, err)
+
+	values, err := Validate(reader, reflect.TypeOf(param))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testSlices)
+
+	require.Equal(t, []int64{1, 2, 3}, result.IDs)
+	require.Len(t, result.Items, 2)
+	require.Equal(t, 1, result.Items[0].Count)
+	require.Equal(t, 2, result.Items[1].Count)
+	require.Equal(t, 3, result.Ptrs[0].Count)
+	require.Equal(t, [2]uint8{4, 5}, result.Fixed)
+	require.Nil(t, result.Tags)
+}
+
+// plainReader hides the optional reader extensions, e.g. ElementReader
+type plainReader struct {
+	reader restrpc.Reader
+}
+
+func (reader plainReader) Search(key string) ([]string, error) { return reader.reader.Search(key) }
+func (reader plainReader) Get() ([]string, error)              { return reader.reader.Get() }
+func (reader plainReader) Path() string                        { return reader.reader.Path() }
+
+func (reader plainReader) Range(f func(key string, reader restrpc.Reader) error) error {
+	return reader.reader.Range(func(key string, child restrpc.Reader) error {
+		return f(key, plainReader{child})
+	})
+}
+
+func (reader plainReader) Reader(key string) restrpc.Reader {
+	return plainReader{reader.reader.Reader(key)}
+}
+
+func TestSliceValidatorPlainReader(t *testing.T) {
+	var param *testSlices
+
+	values, err := Validate(plainReader{NewQueryReader(url.Values{
+		"ids":  []string{"1", "2"},
+		"tags": []string{"a"},
+	})}, reflect.TypeOf(param))
+
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testSlices)
+
+	require.Equal(t, []int64{1, 2}, result.IDs)
+	require.Equal(t, []string{"a"}, result.Tags)
+	require.Nil(t, result.Items)
+}
+
+func TestSliceValidatorErrors(t *testing.T) {
+	var param *testSlices
+
+	_, err := Validate(NewQueryReader(url.Values{"fixed": []string{"1", "2", "3"}}), reflect.TypeOf(param))
+	require.True(t, errors.Is(err, ErrLength), "%s", err)
+
+	_, err = Validate(NewQueryReader(url.Values{"items.0.count": []string{"x"}}), reflect.TypeOf(param))
+	require.True(t, errors.Is(err, ErrNumber), "%s", err)
+	require.Contains(t, err.Error(), "items.0.count")
+
+	reader, err := NewJSONReader([]byte(`{"ids":{"a":1}}`))
+	require.NoError(t, err)
+
+	_, err = Validate(reader, reflect.TypeOf(param))
+	require.True(t, errors.Is(err, ErrInvalidType), "%s", err)
+}
+
+type testLevel int
+
+func (level *testLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*level = 1
+	case "high":
+		*level = 2
+	default:
+		return errors.New("invalid level")
+	}
+
+	return nil
+}
+
+type testPoint struct {
+	X, Y int
+}
+
+func (point *testPoint) UnmarshalJSON(data []byte) error {
+	var values []int
+
+	if err := json.Unmarshal(data, &values); err != nil || len(values) != 2 {
+		return errors.New("invalid point")
+	}
+
+	point.X, point.Y = values[0], values[1]
+
+	return nil
+}
+
+type testText struct {
+	Time     time.Time
+	Day      time.Time   `rest:"day,layout=2006-01-02"`
+	Unix     time.Time   `rest:"unix,unix"`
+	UnixMS   *time.Time  `rest:"unixms,unixms"`
+	Days     []time.Time `rest:"days,layout=2006-01-02"`
+	Duration time.Duration
+	IP       net.IP `rest:"ip"`
+	URL      url.URL
+	Level    testLevel
+	Point    testPoint
+}
+
+func TestTextValidatorQuery(t *testing.T) {
+	var param *testText
+
+	values, err := Validate(NewQueryReader(url.Values{
+		"time":     []string{"2020-01-02T03:04:05.5Z"},
+		"day":      []string{"2020-01-02"},
+		"unix":     []string{"1577934245"},
+		"unixms":   []string{"1577934245500"},
+		"days":     []string{"2020-01-02", "2020-01-03"},
+		"duration": []string{"1m30s"},
+		"ip":       []string{"10.0.0.1"},
+		"url":      []string{"https://example.com/a?b=c"},
+		"level":    []string{"high"},
+		"point":    []string{"[1,2]"},
+	}), reflect.TypeOf(param))
+
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testText)
+
+	require.True(t, time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC).Equal(result.Time))
+	require.True(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Equal(result.Day))
+	require.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(result.Unix))
+	require.True(t, time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC).Equal(*result.UnixMS))
+	require.Len(t, result.Days, 2)
+	require.True(t, time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC).Equal(result.Days[1]))
+	require.Equal(t, 90*time.Second, result.Duration)
+	require.Equal(t, "10.0.0.1", result.IP.String())
+	require.Equal(t, "https://example.com/a?b=c", result.URL.String())
+	require.Equal(t, testLevel(2), result.Level)
+	require.Equal(t, testPoint{X: 1, Y: 2}, result.Point)
+}
+
+func TestTextValidatorJSON(t *testing.T) {
+	var param *testText
+
+	reader, err := NewJSONReader([]byte(`{"time":"2020-01-02T03:04:05Z","day":"2020-01-02","unix":1577934245,"unixms":1577934245500,
+		"days":["2020-01-02"],"duration":"2s","ip":"::1","url":"http://localhost","level":"low","point":[3,4]}`))
+	require.NoError(t, err)
+
+	values, err := Validate(reader, reflect.TypeOf(param))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testText)
+
+	require.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(result.Time))
+	require.True(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Equal(result.Day))
+	require.True(t, result.Unix.Equal(result.Time))
+	require.Equal(t, int64(1577934245500), result.UnixMS.UnixNano()/int64(time.Millisecond))
+	require.Len(t, result.Days, 1)
+	require.Equal(t, 2*time.Second, result.Duration)
+	require.Equal(t, "::1", result.IP.String())
+	require.Equal(t, "localhost", result.URL.Host)
+	require.Equal(t, testLevel(1), result.Level)
+	require.Equal(t, testPoint{X: 3, Y: 4}, result.Point)
+}
+
+func TestTextValidatorErrors(t *testing.T) {
+	var param *testText
+
+	for key, value := range map[string]string{
+		"time":     "2020-01-02",
+		"day":      "02/01/2020",
+		"unix":     "x",
+		"duration": "1 minute",
+		"ip":       "10.0.0",
+		"level":    "medium",
+		"point":    "[1]",
+	} {
+		_, err := Validate(NewQueryReader(url.Values{key: []string{value}}), reflect.TypeOf(param))
+
+		require.True(t, errors.Is(err, ErrFormat), "%s=%s: %v", key, value, err)
+		require.Contains(t, err.Error(), "parameter "+key+" ")
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	metadata, err := ParseTag("at,layout=Mon, 02 Jan 2006 15:04:05 MST,required")
+	require.NoError(t, err)
+	require.Equal(t, "at", metadata.Name)
+	require.Equal(t, time.RFC1123, metadata.Layout)
+	require.True(t, metadata.Required)
+
+	metadata, err = ParseTag("required,at")
+	require.NoError(t, err)
+	require.Equal(t, "at", metadata.Name)
+
+	_, err = ParseTag("at,other")
+	require.True(t, errors.Is(err, ErrTag), "%v", err)
+
+	type testRFC1123 struct {
+		At time.Time `rest:"at,layout=Mon, 02 Jan 2006 15:04:05 MST"`
+	}
+
+	values, err := Validate(NewQueryReader(url.Values{"at": {"Thu, 02 Jan 2020 03:04:05 UTC"}}), reflect.TypeOf(&testRFC1123{}))
+	require.NoError(t, err)
+	require.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(values[0].Interface().(testRFC1123).At))
+
+	type testStray struct {
+		At time.Time `rest:"at,unix,other"`
+	}
+
+	_, err = Fields(reflect.TypeOf(testStray{}))
+	require.True(t, errors.Is(err, ErrTag), "%v", err)
+}
+
+func TestFormatValue(t *testing.T) {
+	day := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, test := range []struct {
+		value    interface{}
+		metadata *Metadata
+		literal  string
+	}{
+		{day, nil, `"2020-01-02T03:04:05Z"`},
+		{day, ParseMetadata("layout=2006-01-02"), `"2020-01-02"`},
+		{&day, ParseMetadata("unix"), `1577934245`},
+		{day, ParseMetadata("unixms"), `1577934245000`},
+		{90 * time.Second, nil, `"1m30s"`},
+		{net.ParseIP("10.0.0.1"), nil, `"10.0.0.1"`},
+		{url.URL{Scheme: "http", Host: "localhost"}, nil, `"http://localhost"`},
+	} {
+		literal, ok, err := FormatValue(reflect.ValueOf(test.value), test.metadata)
+
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, test.literal, string(literal))
+	}
+
+	_, ok, err := FormatValue(reflect.ValueOf(1), nil)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+type bigIntBinder struct {
+}
+
+func (binder *bigIntBinder) Bind(text string, metadata *Metadata) (interface{}, error) {
+	number, ok := new(big.Int).SetString(text, 10)
+
+	if !ok {
+		return nil, errors.New("invalid big int")
+	}
+
+	return number, nil
+}
+
+func (binder *bigIntBinder) Format(value interface{}, metadata *Metadata) (string, error) {
+	return value.(*big.Int).String(), nil
+}
+
+type testCode string
+
+func (code *testCode) UnmarshalText(text []byte) error {
+	*code = testCode("text:" + string(text))
+	return nil
+}
+
+type testCodeBinder struct {
+}
+
+func (binder *testCodeBinder) Bind(text string, metadata *Metadata) (interface{}, error) {
+	return testCode("binder:" + text), nil
+}
+
+func (binder *testCodeBinder) Format(value interface{}, metadata *Metadata) (string, error) {
+	return strings.TrimPrefix(string(*value.(*testCode)), "binder:"), nil
+}
+
+type testChecked struct {
+	Value string
+}
+
+func (checked *testChecked) Validate(reader restrpc.Reader, paramT reflect.Type) ([]reflect.Value, error) {
+	params, err := reader.Get()
+
+	if err != nil || len(params) == 0 {
+		return nil, err
+	}
+
+	return []reflect.Value{reflect.ValueOf(testChecked{Value: "checked:" + params[0]})}, nil
+}
+
+type testBinders struct {
+	Big     *big.Int
+	Numbers []big.Int
+	Code    testCode
+	Checked testChecked
+}
+
+func init() {
+	Register(reflect.TypeOf((*big.Int)(nil)), &bigIntBinder{})
+	Register(reflect.TypeOf(testCode("")), &testCodeBinder{})
+}
+
+func TestBinderRegistry(t *testing.T) {
+	var param *testBinders
+
+	values, err := Validate(NewQueryReader(url.Values{
+		"big":     []string{"123456789012345678901234567890"},
+		"numbers": []string{"1", "2"},
+		"code":    []string{"x"},
+		"checked": []string{"y"},
+	}), reflect.TypeOf(param))
+
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testBinders)
+
+	require.Equal(t, "123456789012345678901234567890", result.Big.String())
+	require.Len(t, result.Numbers, 2)
+	require.Equal(t, int64(2), result.Numbers[1].Int64())
+	require.Equal(t, testCode("binder:x"), result.Code)
+	require.Equal(t, "checked:y", result.Checked.Value)
+
+	reader, err := NewJSONReader([]byte(`{"big":123456789012345678901234567890,"numbers":["3"],"code":"z","checked":"w"}`))
+	require.NoError(t, err)
+
+	values, err = Validate(reader, reflect.TypeOf(param))
+	require.NoError(t, err)
+
+	result = values[0].Interface().(testBinders)
+
+	require.Equal(t, "123456789012345678901234567890", result.Big.String())
+	require.Equal(t, int64(3), result.Numbers[0].Int64())
+	require.Equal(t, testCode("binder:z"), result.Code)
+
+	_, err = Validate(NewQueryReader(url.Values{"big": []string{"1.5"}}), reflect.TypeOf(param))
+	require.True(t, errors.Is(err, ErrFormat), "%v", err)
+
+	literal, ok, err := FormatValue(reflect.ValueOf(result.Big), nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `"123456789012345678901234567890"`, string(literal))
+
+	literal, ok, err = FormatValue(reflect.ValueOf(result.Code), nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `"z"`, string(literal))
+}
+
+type testDefaults struct {
+	Limit   int           `rest:"limit,default=20"`
+	Offset  *int64        `rest:"offset,default=5"`
+	Order   string        `rest:"order,required,default=asc"`
+	Tags    []string      `rest:"tags,default=a|b"`
+	Since   time.Time     `rest:"since,layout=2006-01-02,default=2020-01-02"`
+	Timeout time.Duration `rest:"timeout,default=${TEST_RESTRPC_TIMEOUT}"`
+	Page    testPage
+}
+
+type testPage struct {
+	Size int `rest:"size,default=10"`
+}
+
+type testLateBinder struct {
+	Units int
+	Next  *testLateParent
+}
+
+type testLateParent struct {
+	Price testLateBinder
+	Next  *testLateBinder
+}
+
+type testLateBinderBinder struct {
+}
+
+func (binder *testLateBinderBinder) Bind(text string, metadata *Metadata) (interface{}, error) {
+	units, err := strconv.Atoi(text)
+	return testLateBinder{Units: units}, err
+}
+
+func (binder *testLateBinderBinder) Format(value interface{}, metadata *Metadata) (string, error) {
+	return strconv.Itoa(value.(*testLateBinder).Units), nil
+}
+
+func TestBinderRegisterAfterUse(t *testing.T) {
+	paramT := reflect.TypeOf(&testLateParent{})
+
+	values, err := Validate(NewQueryReader(url.Values{"price.units": {"1"}}), paramT)
+	require.NoError(t, err)
+	require.Equal(t, 1, values[0].Interface().(testLateParent).Price.Units)
+	require.True(t, isRecursive(reflect.TypeOf(&testLateBinder{})))
+	require.Len(t, Parameters(paramT), 4)
+
+	Register(reflect.TypeOf(testLateBinder{}), &testLateBinderBinder{})
+
+	values, err = Validate(NewQueryReader(url.Values{"price": {"2"}}), paramT)
+	require.NoError(t, err)
+	require.Equal(t, 2, values[0].Interface().(testLateParent).Price.Units)
+	require.False(t, isRecursive(reflect.TypeOf(&testLateBinder{})))
+	require.Equal(t, []Parameter{{Name: "price", Type: "validator.testLateBinder"}, {Name: "next", Type: "*validator.testLateBinder"}}, Parameters(paramT))
+}
+
+func TestDefaultValues(t *testing.T) {
+	os.Setenv("TEST_RESTRPC_TIMEOUT", "3s")
+	defer os.Unsetenv("TEST_RESTRPC_TIMEOUT")
+
+	var param *testDefaults
+
+	values, err := Validate(NewQueryReader(url.Values{}), reflect.TypeOf(param))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testDefaults)
+
+	require.Equal(t, 20, result.Limit)
+	require.Equal(t, int64(5), *result.Offset)
+	require.Equal(t, "asc", result.Order)
+	require.Equal(t, []string{"a", "b"}, result.Tags)
+	require.True(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Equal(result.Since))
+	require.Equal(t, 3*time.Second, result.Timeout)
+	require.Equal(t, 10, result.Page.Size)
+
+	reader, err := NewJSONReader([]byte(`{"limit":1,"tags":["c"],"page":{},"timeout":null}`))
+	require.NoError(t, err)
+
+	values, err = Validate(reader, reflect.TypeOf(param))
+	require.NoError(t, err)
+
+	result = values[0].Interface().(testDefaults)
+
+	require.Equal(t, 1, result.Limit)
+	require.Equal(t, int64(5), *result.Offset)
+	require.Len(t, result.Tags, 1)
+	require.Equal(t, 10, result.Page.Size)
+	require.Equal(t, time.Duration(0), result.Timeout)
+
+	type invalid struct {
+		Limit int `rest:"limit,default=x"`
+	}
+
+	_, err = Validate(NewQueryReader(url.Values{}), reflect.TypeOf(&invalid{}))
+	require.True(t, errors.Is(err, ErrNumber), "%v", err)
+
+	// commas are part of the default value, slice values are separated by '|'
+	type commas struct {
+		Filter string   `rest:"filter,default=a,b,required"`
+		Tags   []string `rest:"tags,default=a,b|c"`
+	}
+
+	values, err = Validate(NewQueryReader(url.Values{}), reflect.TypeOf(&commas{}))
+	require.NoError(t, err)
+
+	require.Equal(t, commas{Filter: "a,b", Tags: []string{"a,b", "c"}}, values[0].Interface())
+
+	metadata, err := ParseTag("filter,default=a,b,required")
+	require.NoError(t, err)
+	require.Equal(t, "filter", metadata.Name)
+	require.True(t, metadata.Required)
+}
+
+func TestIsDefault(t *testing.T) {
+	paramT := reflect.TypeOf(testDefaults{})
+
+	field := func(name string) *Metadata {
+		f, _ := paramT.FieldByName(name)
+		return ParseMetadata(f.Tag.Get(MetadataTag))
+	}
+
+	offset := int64(5)
+
+	require.True(t, IsDefault(reflect.ValueOf(20), field("Limit")))
+	require.False(t, IsDefault(reflect.ValueOf(0), field("Limit")))
+	require.True(t, IsDefault(reflect.ValueOf(&offset), field("Offset")))
+	require.True(t, IsDefault(reflect.ValueOf([]string{"a", "b"}), field("Tags")))
+	require.False(t, IsDefault(reflect.ValueOf([]string{"a"}), field("Tags")))
+	require.True(t, IsDefault(reflect.ValueOf(time.Date(2020, 1, 2, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))), field("Since")))
+	require.False(t, IsDefault(reflect.ValueOf(1), nil))
+
+	// the client environment may differ from the server one
+	os.Setenv("TEST_RESTRPC_TIMEOUT", "3s")
+	defer os.Unsetenv("TEST_RESTRPC_TIMEOUT")
+
+	require.False(t, IsDefault(reflect.ValueOf(3*time.Second), field("Timeout")))
+}
+
+func TestParameters(t *testing.T) {
+	parameters := Parameters(reflect.TypeOf(&testDefaults{}))
+
+	require.Len(t, parameters, 7)
+	require.Equal(t, "limit", parameters[0].Name)
+	require.Equal(t, "int", parameters[0].Type)
+	require.Equal(t, "20", *parameters[0].Default)
+	require.True(t, parameters[2].Required)
+	require.Equal(t, "time.Time", parameters[4].Type)
+	require.Equal(t, "page.size", parameters[6].Name)
+}
+
+type testNode struct {
+	Name     string
+	Next     *testNode
+	Children []testNode
+	Page     testPage
+	Last     testPage
+}
+
+func TestParametersRecursive(t *testing.T) {
+	var names []string
+
+	for _, parameter := range Parameters(reflect.TypeOf(&testNode{})) {
+		names = append(names, parameter.Name+" "+parameter.Type)
+	}
+
+	require.Equal(t, []string{
+		"name string",
+		"next *validator.testNode",
+		"children []validator.testNode",
+		"page.size int",
+		"last.size int",
+	}, names)
+
+	reader, err := NewJSONReader([]byte(`{"name":"a","next":{"name":"b","next":{"name":"c"}}}`))
+	require.NoError(t, err)
+
+	values, err := Validate(reader, reflect.TypeOf(&testNode{}))
+	require.NoError(t, err)
+
+	require.Equal(t, "c", values[0].Interface().(testNode).Next.Next.Name)
+	require.Nil(t, values[0].Interface().(testNode).Next.Next.Next)
+
+	reader, err = NewJSONReader([]byte(`{"next":{}}`))
+	require.NoError(t, err)
+
+	values, err = Validate(reader, reflect.TypeOf(&testNode{}))
+	require.NoError(t, err)
+
+	require.NotNil(t, values[0].Interface().(testNode).Next)
+	require.Nil(t, values[0].Interface().(testNode).Next.Next)
+
+	values, err = Validate(NewQueryReader(url.Values{"next.name": {"b"}}), reflect.TypeOf(&testNode{}))
+	require.NoError(t, err)
+
+	require.Equal(t, "b", values[0].Interface().(testNode).Next.Name)
+	require.Nil(t, values[0].Interface().(testNode).Next.Next)
+}
+
+type testBase struct {
+	ID      int64 `rest:"id"`
+	Created string
+}
+
+type TestAudit struct {
+	Creator string
+}
+
+type testEmbedded struct {
+	testBase
+	*TestAudit
+	Created string    // shadows testBase.Created
+	Meta    TestAudit `rest:"meta"`
+	hidden  string
+}
+
+type TestNamed struct {
+	Value int
+}
+
+type testNamedEmbedded struct {
+	TestNamed `rest:"named"`
+}
+
+type testCollision struct {
+	testBase
+	Other testOther
+}
+
+type testOther struct {
+	testBase
+	testDuplicate
+}
+
+type testDuplicate struct {
+	ID int64 `rest:"id"`
+}
+
+func TestEmbeddedFields(t *testing.T) {
+	var param *testEmbedded
+
+	values, err := Validate(NewQueryReader(url.Values{
+		"id":           []string{"1"},
+		"created":      []string{"outer"},
+		"creator":      []string{"c"},
+		"meta.creator": []string{"m"},
+		"hidden":       []string{"h"},
+	}), reflect.TypeOf(param))
+
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testEmbedded)
+
+	require.Equal(t, int64(1), result.ID)
+	require.Equal(t, "", result.testBase.Created)
+	require.Equal(t, "outer", result.Created)
+	require.Equal(t, "c", result.Creator)
+	require.Equal(t, "m", result.Meta.Creator)
+	require.Equal(t, "", result.hidden)
+
+	values, err = Validate(NewQueryReader(url.Values{"named.value": []string{"2"}}), reflect.TypeOf(&testNamedEmbedded{}))
+	require.NoError(t, err)
+	require.Equal(t, 2, values[0].Interface().(testNamedEmbedded).Value)
+
+	fields, err := Fields(reflect.TypeOf(testEmbedded{}))
+	require.NoError(t, err)
+
+	var names []string
+
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+
+	require.Equal(t, []string{"id", "creator", "created", "meta"}, names)
+}
+
+func TestFieldCollision(t *testing.T) {
+	require.NoError(t, Check(reflect.TypeOf(&testEmbedded{})))
+
+	err := Check(reflect.TypeOf(&testCollision{}))
+	require.True(t, errors.Is(err, ErrCollision), "%v", err)
+	require.Contains(t, err.Error(), "testBase.ID")
+	require.Contains(t, err.Error(), "testDuplicate.ID")
+
+	_, err = Validate(NewQueryReader(url.Values{}), reflect.TypeOf(&testOther{}))
+	require.True(t, errors.Is(err, ErrCollision), "%v", err)
+
+	// a shallower field hides the deeper colliding ones like encoding/json
+	type shadowed struct {
+		testBase
+		testDuplicate
+		ID string `rest:"id"`
+	}
+
+	require.NoError(t, Check(reflect.TypeOf(&shadowed{})))
+
+	values, err := Validate(NewQueryReader(url.Values{"id": {"x"}}), reflect.TypeOf(&shadowed{}))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(shadowed)
+
+	require.Equal(t, "x", result.ID)
+	require.Equal(t, int64(0), result.testDuplicate.ID)
+}
+
+type testPatchAddress struct {
+	City     string
+	Zip      string
+	Presence PresenceSet
+}
+
+type testPatch struct {
+	ID       string `rest:"required"`
+	Name     *string
+	Age      int
+	Limit    int `rest:"limit,default=20"`
+	Address  *testPatchAddress
+	Home     *testPatchAddress
+	Tags     []string
+	Presence PresenceSet
+}
+
+func TestPresenceSet(t *testing.T) {
+	reader, err := NewJSONReader([]byte(`{"id":"1","name":null,"age":0,"limit":null,"address":{"city":""},"home":null,"tags":[]}`))
+	require.NoError(t, err)
+
+	values, err := Validate(reader, reflect.TypeOf(&testPatch{}))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testPatch)
+
+	require.Equal(t, PresenceSet{
+		"id":      Present,
+		"name":    Null,
+		"age":     Present,
+		"limit":   Null,
+		"address": Present,
+		"home":    Null,
+		"tags":    Present,
+	}, result.Presence)
+
+	require.True(t, result.Presence.Has("age"))
+	require.True(t, result.Presence.IsNull("name"))
+	require.False(t, result.Presence.Has("missing"))
+	require.Nil(t, result.Name)
+	require.Nil(t, result.Home)
+	require.Equal(t, 0, result.Limit)
+	require.Equal(t, PresenceSet{"city": Present}, result.Address.Presence)
+
+	values, err = Validate(NewQueryReader(url.Values{"id": {"1"}, "address.zip": {"z"}}), reflect.TypeOf(&testPatch{}))
+	require.NoError(t, err)
+
+	result = values[0].Interface().(testPatch)
+
+	require.Equal(t, PresenceSet{"id": Present, "address": Present}, result.Presence)
+	require.Equal(t, 20, result.Limit)
+	require.Equal(t, PresenceSet{"zip": Present}, result.Address.Presence)
+
+	// the presence set is not a parameter
+	for _, parameter := range Parameters(reflect.TypeOf(&testPatch{})) {
+		require.NotContains(t, strings.ToLower(parameter.Name), "presence")
+	}
+}
+
+type testMissingPointer struct {
+	Name    *string
+	Address *testPatchAddress
+	Limit   *int `rest:"default=20"`
+}
+
+func TestMissingPointer(t *testing.T) {
+	reader, err := NewJSONReader([]byte(`{"name":null}`))
+	require.NoError(t, err)
+
+	values, err := Validate(reader, reflect.TypeOf(&testMissingPointer{}))
+	require.NoError(t, err)
+
+	result := values[0].Interface().(testMissingPointer)
+
+	require.Nil(t, result.Name)
+	require.Nil(t, result.Address)
+	require.Equal(t, 20, *result.Limit)
+
+	values, err = Validate(NewQueryReader(url.Values{"name": {"a"}}), reflect.TypeOf(&testMissingPointer{}))
+	require.NoError(t, err)
+
+	result = values[0].Interface().(testMissingPointer)
+
+	require.Equal(t, "a", *result.Name)
+	require.Nil(t, result.Address)
+
+	reader, err = NewJSONReader([]byte(`{"address":{}}`))
+	require.NoError(t, err)
+
+	values, err = Validate(reader, reflect.TypeOf(&testMissingPointer{}))
+	require.NoError(t, err)
+
+	require.NotNil(t, values[0].Interface().(testMissingPointer).Address)
+}
+
+func TestFieldErrors(t *testing.T) {
+	reader, err := NewJSONReader([]byte(`{"a":{"a":"x","b":"y"},"c":1.5}`))
+	require.NoError(t, err)
+
+	_, err = Validate(reader, reflect.TypeOf(&TestB{}))
+
+	var fieldErr *restrpc.FieldError
+
+	require.True(t, errors.As(err, &fieldErr), "%v", err)
+	require.Equal(t, "c", fieldErr.Field)
+	require.True(t, errors.Is(err, ErrFraction), "%v", err)
+
+	type nested struct {
+		Inner struct {
+			Tags []string
+		}
+	}
+
+	reader, err = NewJSONReader([]byte(`{"inner":{"tags":"x"}}`))
+	require.NoError(t, err)
+
+	_, err = Validate(reader, reflect.TypeOf(&nested{}))
+
+	require.True(t, errors.As(err, &fieldErr), "%v", err)
+	require.Equal(t, "inner.tags", fieldErr.Field)
+	require.True(t, errors.Is(err, ErrInvalidType), "%v", err)
+
+	_, err = Validate(NewQueryReader(url.Values{"a.a": {"x"}}), reflect.TypeOf(&TestB{}))
+
+	require.True(t, errors.As(err, &fieldErr), "%v", err)
+	require.Equal(t, "c", fieldErr.Field)
+	require.Equal(t, "expect param c", fieldErr.Error())
+}
+
+type testSelfDefault struct {
+	Name string             `rest:"name,default=x"`
+	Next []*testSelfDefault `rest:"next,default=y"`
+}
+
+func TestFieldIsDefault(t *testing.T) {
+	fields, err := Fields(reflect.TypeOf(testDefaults{}))
+	require.NoError(t, err)
+
+	byName := make(map[string]*Field)
+
+	for i := range fields {
+		byName[fields[i].Name] = &fields[i]
+	}
+
+	offset := int64(5)
+
+	require.True(t, byName["limit"].IsDefault(reflect.ValueOf(20)))
+	require.False(t, byName["limit"].IsDefault(reflect.ValueOf(0)))
+	require.True(t, byName["offset"].IsDefault(reflect.ValueOf(&offset)))
+	require.False(t, byName["offset"].IsDefault(reflect.ValueOf((*int64)(nil))))
+	require.True(t, byName["tags"].IsDefault(reflect.ValueOf([]string{"a", "b"})))
+	require.True(t, byName["since"].IsDefault(reflect.ValueOf(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))))
+	require.False(t, byName["timeout"].IsDefault(reflect.ValueOf(time.Duration(0))))
+
+	// composite defaults are not bound while the type is resolved
+	fields, err = Fields(reflect.TypeOf(testSelfDefault{}))
+	require.NoError(t, err)
+	require.True(t, fields[0].IsDefault(reflect.ValueOf("x")))
+	require.False(t, fields[1].IsDefault(reflect.ValueOf([]*testSelfDefault{})))
+}